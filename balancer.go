@@ -0,0 +1,125 @@
+package microservicetransport
+
+import (
+	"math/rand"
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// Balancer - Chooses the endpoint each attempt at a call is sent to, when the
+// service resolves to more than one.
+//
+// Endpoints are passed as the URL of the resource on each of them, which only
+// differ by host.
+type Balancer interface {
+	// Pick - Choose the index of the endpoint to send an attempt to. The done
+	// function is called once the response to the attempt arrives.
+	Pick(endpoints []*url.URL) (pick int, done func())
+}
+
+// RoundRobinBalancer - Sends attempts to each endpoint in turn.
+// The zero value is ready to use, and it is safe for concurrent use.
+type RoundRobinBalancer struct {
+	next uint64
+}
+
+// Pick - Choose the next endpoint in turn.
+func (b *RoundRobinBalancer) Pick(endpoints []*url.URL) (int, func()) {
+	next := atomic.AddUint64(&b.next, 1) - 1
+
+	return int(next % uint64(len(endpoints))), func() {}
+}
+
+// RandomBalancer - Sends attempts to an endpoint chosen at random.
+type RandomBalancer struct{}
+
+// Pick - Choose an endpoint at random.
+func (RandomBalancer) Pick(endpoints []*url.URL) (int, func()) {
+	return rand.Intn(len(endpoints)), func() {}
+}
+
+// LeastOutstandingBalancer - Sends attempts to the endpoint with the fewest
+// attempts awaiting a response, picking the first of any ties.
+// The zero value is ready to use, and it is safe for concurrent use.
+type LeastOutstandingBalancer struct {
+	outstanding outstandingCounts
+}
+
+// Pick - Choose the endpoint with the fewest outstanding attempts.
+func (b *LeastOutstandingBalancer) Pick(endpoints []*url.URL) (int, func()) {
+	b.outstanding.Lock()
+	defer b.outstanding.Unlock()
+
+	pick := 0
+	for i := range endpoints[1:] {
+		if b.outstanding.get(endpoints[i+1]) < b.outstanding.get(endpoints[pick]) {
+			pick = i + 1
+		}
+	}
+
+	return pick, b.outstanding.start(endpoints[pick])
+}
+
+// PowerOfTwoBalancer - Sends attempts to whichever of two endpoints chosen at
+// random has fewer attempts awaiting a response. This spreads load nearly as
+// well as LeastOutstandingBalancer, without every client piling onto the same
+// endpoint.
+// The zero value is ready to use, and it is safe for concurrent use.
+type PowerOfTwoBalancer struct {
+	outstanding outstandingCounts
+}
+
+// Pick - Choose the less loaded of two random endpoints.
+func (b *PowerOfTwoBalancer) Pick(endpoints []*url.URL) (int, func()) {
+	b.outstanding.Lock()
+	defer b.outstanding.Unlock()
+
+	pick := rand.Intn(len(endpoints))
+	if len(endpoints) > 1 {
+		// Choose a second endpoint distinct from the first.
+		other := rand.Intn(len(endpoints) - 1)
+		if other >= pick {
+			other++
+		}
+
+		if b.outstanding.get(endpoints[other]) < b.outstanding.get(endpoints[pick]) {
+			pick = other
+		}
+	}
+
+	return pick, b.outstanding.start(endpoints[pick])
+}
+
+// outstandingCounts - Counts the attempts awaiting a response per endpoint host.
+// Callers must hold the lock.
+type outstandingCounts struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+// get - Get the number of outstanding attempts for an endpoint.
+func (o *outstandingCounts) get(endpoint *url.URL) int {
+	return o.counts[endpoint.Host]
+}
+
+// start - Count an attempt against an endpoint, returning a function which
+// stops counting it.
+func (o *outstandingCounts) start(endpoint *url.URL) func() {
+	if o.counts == nil {
+		o.counts = make(map[string]int)
+	}
+	o.counts[endpoint.Host]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			o.Lock()
+			defer o.Unlock()
+
+			if o.counts[endpoint.Host]--; o.counts[endpoint.Host] <= 0 {
+				delete(o.counts, endpoint.Host)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package microservicetransport
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func testEndpoints(hosts ...string) []*url.URL {
+	endpoints := []*url.URL{}
+	for _, host := range hosts {
+		endpoints = append(endpoints, &url.URL{Scheme: "http", Host: host, Path: "/things"})
+	}
+
+	return endpoints
+}
+
+func TestRoundRobinBalancer_Pick(t *testing.T) {
+	balancer := &RoundRobinBalancer{}
+	endpoints := testEndpoints("a", "b", "c")
+
+	picks := []int{}
+	for i := 0; i < 6; i++ {
+		pick, done := balancer.Pick(endpoints)
+		done()
+		picks = append(picks, pick)
+	}
+
+	expected := []int{0, 1, 2, 0, 1, 2}
+	for i := range expected {
+		if picks[i] != expected[i] {
+			t.Fatalf("TestRoundRobinBalancer_Pick: expected %v got %v", expected, picks)
+		}
+	}
+}
+
+func TestRandomBalancer_Pick(t *testing.T) {
+	endpoints := testEndpoints("a", "b", "c")
+
+	counts := make([]int, len(endpoints))
+	for i := 0; i < 300; i++ {
+		pick, done := RandomBalancer{}.Pick(endpoints)
+		done()
+		counts[pick]++
+	}
+
+	for i, count := range counts {
+		if count == 0 {
+			t.Errorf("TestRandomBalancer_Pick: expected endpoint %d to be picked, got %v", i, counts)
+		}
+	}
+}
+
+func TestLeastOutstandingBalancer_Pick(t *testing.T) {
+	balancer := &LeastOutstandingBalancer{}
+	endpoints := testEndpoints("a", "b", "c")
+
+	// Hold an attempt open against each of the first two endpoints.
+	first, doneFirst := balancer.Pick(endpoints)
+	second, doneSecond := balancer.Pick(endpoints)
+	if first != 0 || second != 1 {
+		t.Fatalf("TestLeastOutstandingBalancer_Pick: expected picks 0, 1 got %d, %d", first, second)
+	}
+
+	third, doneThird := balancer.Pick(endpoints)
+	if third != 2 {
+		t.Errorf("TestLeastOutstandingBalancer_Pick: expected pick 2 got %d", third)
+	}
+	doneThird()
+
+	// Once the second attempt is done its endpoint is the least loaded.
+	doneSecond()
+	doneSecond()
+	fourth, doneFourth := balancer.Pick(endpoints)
+	if fourth != 1 {
+		t.Errorf("TestLeastOutstandingBalancer_Pick: expected pick 1 got %d", fourth)
+	}
+	doneFourth()
+	doneFirst()
+
+	if len(balancer.outstanding.counts) != 0 {
+		t.Errorf("TestLeastOutstandingBalancer_Pick: expected no outstanding attempts got %v", balancer.outstanding.counts)
+	}
+}
+
+func TestPowerOfTwoBalancer_Pick(t *testing.T) {
+	balancer := &PowerOfTwoBalancer{}
+	endpoints := testEndpoints("a", "b", "c")
+
+	// Load up the first endpoint, which should then never be picked.
+	for i := 0; i < 3; i++ {
+		balancer.outstanding.start(endpoints[0])
+	}
+
+	counts := make([]int, len(endpoints))
+	for i := 0; i < 300; i++ {
+		pick, done := balancer.Pick(endpoints)
+		done()
+		counts[pick]++
+	}
+
+	if counts[0] != 0 || counts[1] == 0 || counts[2] == 0 {
+		t.Errorf("TestPowerOfTwoBalancer_Pick: expected the loaded endpoint to be avoided, got %v", counts)
+	}
+
+	if pick, done := balancer.Pick(endpoints[:1]); pick != 0 {
+		t.Errorf("TestPowerOfTwoBalancer_Pick: expected pick 0 of a single endpoint got %d", pick)
+	} else {
+		done()
+	}
+}
+
+func TestService_Call_balancer(t *testing.T) {
+	// Start two HTTP servers to act as instances of the service, each
+	// replying with its own name.
+	instances := []*httptest.Server{}
+	for _, name := range []string{"first", "second"} {
+		name := name
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name+r.URL.Path)
+		}))
+		defer ts.Close()
+		instances = append(instances, ts)
+	}
+
+	service := NewService(DefaultHttpClient(), "master", "staging", "services", "orders")
+	service.Resolver = &StaticResolver{Entries: []StaticEntry{
+		{Name: "orders", URLs: []string{instances[0].URL, instances[1].URL}},
+	}}
+	service.Balancer = &RoundRobinBalancer{}
+
+	replies := []string{}
+	for i := 0; i < 4; i++ {
+		if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+			t.Fatalf("TestService_Call_balancer: %s", err)
+		}
+
+		resp, err := service.Call()
+		if err != nil {
+			t.Fatalf("TestService_Call_balancer: %s", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		replies = append(replies, string(body))
+
+		if endpoint := service.current.Endpoint(); endpoint.Host != resp.Request.URL.Host {
+			t.Errorf("TestService_Call_balancer: expected endpoint %v got %v", resp.Request.URL.Host, endpoint.Host)
+		}
+	}
+
+	expected := "first/things,second/things,first/things,second/things"
+	if strings.Join(replies, ",") != expected {
+		t.Errorf("TestService_Call_balancer: expected %v got %v", expected, strings.Join(replies, ","))
+	}
+}
+
+func TestCloudService_Call_balancer(t *testing.T) {
+	// Start two fake API gateway instances, each replying with its own name.
+	gateways := []*fakeGateway{}
+	for _, name := range []string{"first", "second"} {
+		name := name
+		ts := newFakeGateway(testGatewayToken, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name+r.URL.Path)
+		})
+		defer ts.Close()
+		gateways = append(gateways, ts)
+	}
+
+	service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "orders", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+	service.TokenCache = NewTokenCache()
+	service.GatewayUrls = []string{gateways[0].URL, gateways[1].URL}
+	service.Balancer = &RoundRobinBalancer{}
+
+	replies := []string{}
+	for i := 0; i < 2; i++ {
+		if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+			t.Fatalf("TestCloudService_Call_balancer: %s", err)
+		}
+
+		resp, err := service.Call()
+		if err != nil {
+			t.Fatalf("TestCloudService_Call_balancer: %s", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		replies = append(replies, string(body))
+	}
+
+	expected := "first/services/orders/things,second/services/orders/things"
+	if strings.Join(replies, ",") != expected {
+		t.Errorf("TestCloudService_Call_balancer: expected %v got %v", expected, strings.Join(replies, ","))
+	}
+}
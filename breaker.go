@@ -0,0 +1,180 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	transportErrors "github.com/LUSHDigital/microservice-transport-golang/errors"
+)
+
+// BreakerState - State of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed - Calls flow through to the service as normal.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen - Calls are rejected without reaching the service.
+	BreakerOpen
+
+	// BreakerHalfOpen - A trial call is let through to check whether the
+	// service has recovered.
+	BreakerHalfOpen
+)
+
+// String - Get the name of the breaker state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerPolicy - Decides when the circuit for a service opens and closes.
+type CircuitBreakerPolicy struct {
+	FailureThreshold int           // Consecutive failures which open the circuit.
+	CoolDown         time.Duration // How long the circuit stays open before a trial call.
+	SuccessThreshold int           // Successful trial calls which close the circuit.
+}
+
+// DefaultCircuitBreakerPolicy - Get a circuit breaker policy which opens after
+// 5 consecutive failures and tries the service again after 10 seconds.
+func DefaultCircuitBreakerPolicy() *CircuitBreakerPolicy {
+	return &CircuitBreakerPolicy{
+		FailureThreshold: 5,
+		CoolDown:         10 * time.Second,
+		SuccessThreshold: 1,
+	}
+}
+
+// circuitBreaker - Tracks the health of calls to a single service identity.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int       // Consecutive failures while closed.
+	successes int       // Successful trial calls while half-open.
+	openedAt  time.Time // When the circuit last opened.
+	trial     bool      // Whether a trial call is in flight while half-open.
+}
+
+// circuitBreakers - Circuit breakers for every service identity in the process.
+var circuitBreakers = struct {
+	sync.Mutex
+	breakers map[Identity]*circuitBreaker
+}{breakers: make(map[Identity]*circuitBreaker)}
+
+// breakerFor - Get the circuit breaker for a service identity.
+func breakerFor(identity Identity) *circuitBreaker {
+	circuitBreakers.Lock()
+	defer circuitBreakers.Unlock()
+
+	breaker, ok := circuitBreakers.breakers[identity]
+	if !ok {
+		breaker = &circuitBreaker{}
+		circuitBreakers.breakers[identity] = breaker
+	}
+
+	return breaker
+}
+
+// GetBreakerState - Get the state of the circuit breaker for the service.
+func (s *Service) GetBreakerState() BreakerState {
+	if s.CircuitBreaker == nil {
+		return BreakerClosed
+	}
+
+	return breakerFor(s.GetIdentity()).currentState(s.CircuitBreaker)
+}
+
+// currentState - Get the state of the breaker, taking the cool down into account.
+func (b *circuitBreaker) currentState(policy *CircuitBreakerPolicy) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.coolDown(policy)
+
+	return b.state
+}
+
+// allow - Check whether a call may go through, returning an error if the
+// circuit is open.
+func (b *circuitBreaker) allow(policy *CircuitBreakerPolicy, identity Identity) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.coolDown(policy)
+
+	switch {
+	case b.state == BreakerOpen, b.state == BreakerHalfOpen && b.trial:
+		return transportErrors.CircuitOpenError{Service: identity.String()}
+	case b.state == BreakerHalfOpen:
+		b.trial = true
+	}
+
+	return nil
+}
+
+// record - Record the outcome of a call which was allowed through.
+func (b *circuitBreaker) record(policy *CircuitBreakerPolicy, resp *http.Response, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The caller giving up says nothing about the service.
+	if errors.Is(err, context.Canceled) {
+		b.trial = false
+		return
+	}
+
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+
+	switch b.state {
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+		if b.failures >= policy.FailureThreshold {
+			b.open()
+		}
+
+	case BreakerHalfOpen:
+		b.trial = false
+		if failed {
+			b.open()
+			return
+		}
+
+		b.successes++
+		if b.successes >= policy.SuccessThreshold {
+			b.state = BreakerClosed
+			b.failures = 0
+		}
+	}
+}
+
+// open - Open the circuit.
+func (b *circuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+	b.failures = 0
+	b.successes = 0
+	b.trial = false
+}
+
+// coolDown - Move an open circuit to half-open once the cool down has passed.
+func (b *circuitBreaker) coolDown(policy *CircuitBreakerPolicy) {
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= policy.CoolDown {
+		b.state = BreakerHalfOpen
+	}
+}
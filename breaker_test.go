@@ -0,0 +1,138 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	transportErrors "github.com/LUSHDigital/microservice-transport-golang/errors"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	policy := &CircuitBreakerPolicy{
+		FailureThreshold: 2,
+		CoolDown:         20 * time.Millisecond,
+		SuccessThreshold: 1,
+	}
+	identity := Identity{Name: "myservice"}
+	ok := &http.Response{StatusCode: http.StatusOK}
+	broken := &http.Response{StatusCode: http.StatusBadGateway}
+
+	breaker := &circuitBreaker{}
+
+	// Failures below the threshold leave the circuit closed.
+	breaker.record(policy, broken, nil)
+	breaker.record(policy, ok, nil)
+	breaker.record(policy, nil, errors.New("connection refused"))
+	if state := breaker.currentState(policy); state != BreakerClosed {
+		t.Fatalf("TestCircuitBreaker: expected %v got %v", BreakerClosed, state)
+	}
+
+	// Reaching the threshold opens it.
+	breaker.record(policy, broken, nil)
+	if state := breaker.currentState(policy); state != BreakerOpen {
+		t.Fatalf("TestCircuitBreaker: expected %v got %v", BreakerOpen, state)
+	}
+	if err := breaker.allow(policy, identity); !errors.As(err, &transportErrors.CircuitOpenError{}) {
+		t.Fatalf("TestCircuitBreaker: expected circuit open error got %v", err)
+	}
+
+	// After the cool down a single trial call is let through.
+	time.Sleep(policy.CoolDown)
+	if state := breaker.currentState(policy); state != BreakerHalfOpen {
+		t.Fatalf("TestCircuitBreaker: expected %v got %v", BreakerHalfOpen, state)
+	}
+	if err := breaker.allow(policy, identity); err != nil {
+		t.Fatalf("TestCircuitBreaker: expected trial call to be allowed got %v", err)
+	}
+	if err := breaker.allow(policy, identity); err == nil {
+		t.Fatalf("TestCircuitBreaker: expected second trial call to be rejected")
+	}
+
+	// A failed trial opens the circuit again.
+	breaker.record(policy, broken, nil)
+	if state := breaker.currentState(policy); state != BreakerOpen {
+		t.Fatalf("TestCircuitBreaker: expected %v got %v", BreakerOpen, state)
+	}
+
+	// A successful trial closes it.
+	time.Sleep(policy.CoolDown)
+	breaker.allow(policy, identity)
+	breaker.record(policy, ok, nil)
+	if state := breaker.currentState(policy); state != BreakerClosed {
+		t.Fatalf("TestCircuitBreaker: expected %v got %v", BreakerClosed, state)
+	}
+}
+
+func TestService_CircuitBreaker(t *testing.T) {
+	var (
+		calls   int32
+		healthy int32
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	service := NewService(redirectClient(ts), "master", "staging", "services", "breakerservice")
+	service.CircuitBreaker = &CircuitBreakerPolicy{
+		FailureThreshold: 2,
+		CoolDown:         50 * time.Millisecond,
+		SuccessThreshold: 1,
+	}
+
+	call := func() (*http.Response, error) {
+		prepared, err := service.Prepare(context.Background(), &Request{
+			Method:   http.MethodGet,
+			Resource: "things",
+		})
+		if err != nil {
+			t.Fatalf("TestService_CircuitBreaker: %s", err)
+		}
+
+		resp, err := prepared.Do(context.Background())
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		return resp, err
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := call(); err != nil {
+			t.Fatalf("TestService_CircuitBreaker: %s", err)
+		}
+	}
+
+	if state := service.GetBreakerState(); state != BreakerOpen {
+		t.Fatalf("TestService_CircuitBreaker: expected %v got %v", BreakerOpen, state)
+	}
+
+	_, err := call()
+	if !errors.As(err, &transportErrors.CircuitOpenError{}) {
+		t.Errorf("TestService_CircuitBreaker: expected circuit open error got %v", err)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("TestService_CircuitBreaker: expected %v calls to reach the service got %v", 2, n)
+	}
+
+	// Once the service recovers and the cool down passes, the circuit closes.
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(service.CircuitBreaker.CoolDown)
+
+	if _, err := call(); err != nil {
+		t.Fatalf("TestService_CircuitBreaker: %s", err)
+	}
+
+	if state := service.GetBreakerState(); state != BreakerClosed {
+		t.Errorf("TestService_CircuitBreaker: expected %v got %v", BreakerClosed, state)
+	}
+}
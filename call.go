@@ -0,0 +1,268 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// PreparedCall - A request to a service resource which is ready to be done.
+//
+// A prepared call holds all of its own state, so any number of them can be in
+// flight against the same service at once, and each can be done more than once.
+type PreparedCall struct {
+	Request     *Request      // Request the call was prepared from.
+	HTTPRequest *http.Request // HTTP request sent to the service.
+
+	service      *Service      // Service the call was prepared for.
+	identity     Identity      // Identity of the service the call was prepared for.
+	client       *http.Client  // http client implementation
+	interceptors []Interceptor // Interceptors every request sent goes through, in order.
+
+	endpoints []*url.URL // URL of the resource on each endpoint the call can be sent to.
+
+	mu       sync.Mutex
+	endpoint *url.URL // URL the latest attempt was sent to.
+}
+
+// newPreparedCall - Prepare a call to the provided resource URLs, one for each
+// endpoint the call can be sent to. The HTTP request is built for the first.
+func newPreparedCall(ctx context.Context, service *Service, client *http.Client, request *Request, resourceUrls []string) (*PreparedCall, error) {
+	endpoints := make([]*url.URL, 0, len(resourceUrls))
+	for _, resourceUrl := range resourceUrls {
+		// Append the query string if we have any.
+		if len(request.Query) > 0 {
+			resourceUrl = fmt.Sprintf("%s?%s", resourceUrl, request.Query.Encode())
+		}
+
+		endpoint, err := url.Parse(resourceUrl)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	// Buffer the body so the request can be replayed.
+	body, err := request.replayableBody()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the request.
+	httpRequest, err := http.NewRequestWithContext(ctx, request.Method, endpoints[0].String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Share the first endpoint with the HTTP request, so changes made to the
+	// request before it is done are honoured.
+	endpoints[0] = httpRequest.URL
+
+	// Pass on the request ID and headers of the inbound request, if any.
+	forwardHeaders(ctx, httpRequest, service.ForwardHeaders)
+
+	return &PreparedCall{
+		Request:      request,
+		HTTPRequest:  httpRequest,
+		service:      service,
+		identity:     service.GetIdentity(),
+		client:       client,
+		interceptors: service.Interceptors,
+		endpoints:    endpoints,
+	}, nil
+}
+
+// intercept - Put built-in interceptors in front of those of the service.
+func (p *PreparedCall) intercept(interceptors ...Interceptor) {
+	p.interceptors = append(interceptors, p.interceptors...)
+}
+
+// Endpoint - Get the URL the latest attempt at the call was sent to, or nil
+// if the call has not been done.
+func (p *PreparedCall) Endpoint() *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.endpoint
+}
+
+// Do - Do the prepared call, bound to the provided context.
+//
+// Failed attempts are retried according to the retry policy of the service,
+// and every attempt goes through the circuit breaker of the service. Each
+// attempt is sent to the endpoint chosen by the balancer of the service,
+// through the interceptors of the call.
+func (p *PreparedCall) Do(ctx context.Context) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := p.attempt(ctx, attempt)
+
+		delay, retry := p.service.Retry.next(attempt, p.HTTPRequest.Method, resp, err)
+		if !retry || ctx.Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt - Make a single attempt at the call through the circuit breaker.
+func (p *PreparedCall) attempt(ctx context.Context, attempt int) (*http.Response, error) {
+	policy := p.service.CircuitBreaker
+	if policy == nil {
+		return p.send(ctx, attempt)
+	}
+
+	breaker := breakerFor(p.identity)
+	if err := breaker.allow(policy, p.identity); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.send(ctx, attempt)
+	breaker.record(policy, resp, err)
+
+	return resp, err
+}
+
+// pickEndpoint - Choose the endpoint to send an attempt to, returning a
+// function to call once the response arrives.
+func (p *PreparedCall) pickEndpoint() (*url.URL, func()) {
+	endpoints := p.endpoints
+	if p.service.OutlierDetection != nil && len(endpoints) > 0 {
+		endpoints = outlierDetectorFor(p.identity).available(endpoints)
+	}
+
+	var endpoint *url.URL
+	done := func() {}
+
+	switch {
+	case len(endpoints) == 0:
+		// Calls wrapping a request built elsewhere go where it points.
+		endpoint = p.HTTPRequest.URL
+	case len(endpoints) == 1 || p.service.Balancer == nil:
+		endpoint = endpoints[0]
+	default:
+		var pick int
+		pick, done = p.service.Balancer.Pick(endpoints)
+		endpoint = endpoints[pick]
+	}
+
+	p.mu.Lock()
+	p.endpoint = endpoint
+	p.mu.Unlock()
+
+	return endpoint, done
+}
+
+// send - Send the call to an endpoint of the service, recording the outcome
+// against the endpoint if outlier detection is enabled.
+func (p *PreparedCall) send(ctx context.Context, attempt int) (*http.Response, error) {
+	endpoint, done := p.pickEndpoint()
+	defer done()
+
+	resp, err := p.sendTo(ctx, endpoint, attempt)
+
+	if policy := p.service.OutlierDetection; policy != nil && len(p.endpoints) > 0 {
+		outlierDetectorFor(p.identity).record(policy, endpoint, resp, err)
+	}
+
+	return resp, err
+}
+
+// sendTo - Send the call to an endpoint through the interceptors of the call.
+func (p *PreparedCall) sendTo(ctx context.Context, endpoint *url.URL, attempt int) (*http.Response, error) {
+	req, err := p.newHTTPRequest(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	invoke := chain(p.interceptors, p.do)
+
+	return invoke(&Invocation{
+		Request:     p.Request,
+		HTTPRequest: req,
+		Identity:    p.identity,
+		Attempt:     attempt,
+	})
+}
+
+// do - Send a request to the service, tracing it, recording metrics for it
+// and logging it if the service has a tracer, collects metrics or logs calls.
+func (p *PreparedCall) do(invocation *Invocation) (*http.Response, error) {
+	req := invocation.HTTPRequest
+
+	resource := resourceTemplate(strings.TrimPrefix(req.URL.Path, "/"))
+	if invocation.Request != nil {
+		resource = invocation.Request.template()
+	}
+
+	labels := MetricLabels{
+		Name:      invocation.Identity.Name,
+		Namespace: invocation.Identity.Namespace,
+		Version:   invocation.Identity.Version,
+		Method:    req.Method,
+		Resource:  resource,
+		Endpoint:  req.URL.Host,
+	}
+
+	return p.service.Tracer.traceCall(invocation.Identity, invocation.Attempt, resource, req, func() (*http.Response, error) {
+		return p.service.Logger.logCall("call", invocation.Identity, invocation.Attempt, req, func() (*http.Response, error) {
+			return collectMetrics(p.service.Metrics, labels, func() (*http.Response, error) {
+				return p.client.Do(req)
+			})
+		})
+	})
+}
+
+// newHTTPRequest - Copy the HTTP request for a single attempt at the call to
+// an endpoint.
+func (p *PreparedCall) newHTTPRequest(ctx context.Context, endpoint *url.URL) (*http.Request, error) {
+	req := p.HTTPRequest.Clone(ctx)
+
+	if endpoint != p.HTTPRequest.URL {
+		u := *endpoint
+		req.URL = &u
+		req.Host = u.Host
+	}
+
+	if p.HTTPRequest.GetBody != nil {
+		body, err := p.HTTPRequest.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("cannot replay request body: %s", err)
+		}
+		req.Body = body
+	}
+
+	return req, nil
+}
+
+// replayRequest - Copy a HTTP request which has already been sent, so it can
+// be sent again.
+func replayRequest(req *http.Request) (*http.Request, error) {
+	replay := req.Clone(req.Context())
+
+	switch {
+	case req.GetBody != nil:
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("cannot replay request body: %s", err)
+		}
+		replay.Body = body
+	case req.Body != nil && req.Body != http.NoBody:
+		return nil, errors.New("cannot replay request body: it cannot be read again")
+	}
+
+	return replay, nil
+}
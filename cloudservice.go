@@ -2,12 +2,16 @@ package microservicetransport
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
-	"strings"
+	"sync"
 
 	"github.com/LUSHDigital/microservice-core-golang/response"
 	"github.com/LUSHDigital/microservice-transport-golang/config"
@@ -27,6 +31,8 @@ type CloudService struct {
 	Service                      // Inherit all properties of a normal service.
 	Credentials *AuthCredentials // Authentication credentials for cloud service calls.
 	Client      *http.Client
+	TokenCache  *TokenCache // Cache of API gateway tokens, DefaultTokenCache if nil.
+	GatewayUrls []string    // URLs of API gateway instances to balance calls across, GetApiGatewayUrl if empty.
 }
 
 // NewCloudService - Prepare a new CloudService struct with the provided parameters.
@@ -45,27 +51,37 @@ func NewCloudService(client *http.Client, branch, env, namespace, name string, c
 }
 
 // authenticate - Authenticate against the API gateway and return an auth token.
-func (c *CloudService) authenticate(request *Request) (*models.Token, error) {
+func (c *CloudService) authenticate(ctx context.Context, gatewayUrl string) (*models.Token, error) {
 	loginBody := new(bytes.Buffer)
 	if err := json.NewEncoder(loginBody).Encode(c.Credentials); err != nil {
-		return nil, fmt.Errorf("cannot encode json: %s", err)
+		return nil, fmt.Errorf("cannot encode json: %w", err)
 	}
 
-	loginReq, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s", c.GetApiGatewayUrl(request), "login"), loginBody)
+	loginReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s", gatewayUrl, "login"), loginBody)
 	if err != nil {
-		return nil, fmt.Errorf("cannot build login request: %s", err)
+		return nil, fmt.Errorf("cannot build login request: %w", err)
 	}
 
-	loginResp, err := c.Client.Do(loginReq)
+	// Logins are recorded as a series of their own.
+	labels := MetricLabels{
+		Name:     "api-gateway",
+		Method:   loginReq.Method,
+		Resource: "login",
+		Endpoint: loginReq.URL.Host,
+	}
+	loginResp, err := c.Logger.logCall("api gateway login", Identity{Name: labels.Name}, 0, loginReq, func() (*http.Response, error) {
+		return collectMetrics(c.Metrics, labels, func() (*http.Response, error) {
+			return c.Client.Do(loginReq)
+		})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("cannot perform login request: %s", err)
+		return nil, fmt.Errorf("cannot perform login request: %w", err)
 	}
+	defer loginResp.Body.Close()
 
-	// Decode response.
-	serviceResponse := response.Response{}
-	jsonErr := json.NewDecoder(loginResp.Body).Decode(&serviceResponse)
-	if jsonErr != nil {
-		return nil, fmt.Errorf("cannot decode login response: %s", err)
+	loginRespBody, err := ioutil.ReadAll(loginResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read login response: %w", err)
 	}
 
 	// Handle any error codes.
@@ -80,91 +96,230 @@ func (c *CloudService) authenticate(request *Request) (*models.Token, error) {
 
 		// Something somewhere broken!
 	default:
-		return nil, fmt.Errorf("api gateway login failed: %s", serviceResponse.Message)
+		return nil, fmt.Errorf("api gateway login failed: %w", transportErrors.NewServiceError("api-gateway", loginResp, loginRespBody))
+	}
+
+	// Decode response.
+	serviceResponse := response.Response{}
+	if err := json.Unmarshal(loginRespBody, &serviceResponse); err != nil {
+		return nil, fmt.Errorf("cannot decode login response: %w", err)
 	}
 
 	// Extract the consumer from the response.
 	var consumer *models.Consumer
 	consumerErr := serviceResponse.ExtractData("consumer", &consumer)
 	if consumerErr != nil {
-		return nil, fmt.Errorf("could not extract consumer data: %s", consumerErr)
+		return nil, fmt.Errorf("could not extract consumer data: %w", consumerErr)
 	}
 
-	if len(consumer.Tokens) == 0 {
+	if consumer == nil || len(consumer.Tokens) == 0 {
 		return nil, transportErrors.ConsumerHasNoTokensError{}
 	}
 
 	return consumer.Tokens[0], nil
 }
 
-// GetApiGatewayUrl - Get the url of the API gateway.
+// getToken - Get an auth token for the API gateway, from the token cache if
+// a valid one is held.
+func (c *CloudService) getToken(ctx context.Context, gatewayUrl string) (*models.Token, error) {
+	key := tokenCacheKey(gatewayUrl, c.Credentials)
+
+	return c.getTokenCache().Get(ctx, key, func() (*models.Token, error) {
+		return c.authenticate(ctx, gatewayUrl)
+	})
+}
+
+// getTokenCache - Get the token cache used by the service.
+func (c *CloudService) getTokenCache() *TokenCache {
+	if c.TokenCache != nil {
+		return c.TokenCache
+	}
+
+	return DefaultTokenCache
+}
+
+// GetApiGatewayUrl - Get the url of the API gateway. An empty string is
+// returned if the config of the service cannot build one, e.g. for an
+// unknown environment; use GetApiGatewayUrlE to find out why.
 func (c *CloudService) GetApiGatewayUrl(request *Request) string {
-	// Check if a full URL is set in the environment.
-	if config.GetGatewayUrl() != "" {
-		return config.GetGatewayUrl()
+	gatewayUrl, _ := c.GetApiGatewayUrlE(request)
+	return gatewayUrl
+}
+
+// GetApiGatewayUrlE - Get the url of the API gateway, from the gateway
+// instances or config set on the service, or else from the environment and
+// the config file it names. Environments missing from the config return an
+// error wrapping config.ErrUnknownEnvironment.
+func (c *CloudService) GetApiGatewayUrlE(request *Request) (string, error) {
+	// Use the first gateway instance if they are set on the service.
+	if len(c.GatewayUrls) > 0 {
+		return c.GatewayUrls[0], nil
 	}
 
-	// Fallback to constructing the URL ourselves.
-	if c.Environment == "staging" {
-		return fmt.Sprintf("%s://%s-%s.%s", request.getProtocol(), config.GetGatewayUri(), c.Environment, config.GetServiceDomain())
+	cfg := c.Config
+	if cfg == nil {
+		var err error
+		if cfg, err = config.FromEnvAndFile(); err != nil {
+			return "", fmt.Errorf("cannot build api gateway url: %w", err)
+		}
 	}
 
-	return fmt.Sprintf("%s://%s.%s", request.getProtocol(), config.GetGatewayUri(), config.GetServiceDomain())
+	return cfg.GetGatewayUrl(request.getProtocol(), c.Environment)
 }
 
 // Call - Do the current service request.
 func (c *CloudService) Call() (*http.Response, error) {
-	return c.Client.Do(c.CurrentRequest)
+	return c.CallContext(c.CurrentRequest.Context())
+}
+
+// CallContext - Do the current service request, bound to the provided context.
+//
+// If the service rejects the auth token the request was dialled with, a new
+// token is fetched from the API gateway and the request is replayed once.
+func (c *CloudService) CallContext(ctx context.Context) (*http.Response, error) {
+	return c.currentCall(c.Client).Do(ctx)
 }
 
 // Dial - Create a request to a service resource.
 func (c *CloudService) Dial(request *Request) error {
-	if c.Credentials.Email == "" || c.Credentials.Password == "" {
-		return errors.New("cannot authenticate for cloud service: missing credentials")
+	return c.DialContext(context.Background(), request)
+}
+
+// DialContext - Create a request to a service resource, bound to the provided
+// context. The context also applies to the API gateway login.
+func (c *CloudService) DialContext(ctx context.Context, request *Request) error {
+	call, err := c.Prepare(ctx, request)
+	if err != nil {
+		return err
 	}
 
-	token, err := c.authenticate(request)
+	c.setCurrentCall(call)
+
+	return nil
+}
+
+// Prepare - Prepare a call to a service resource, bound to the provided
+// context. The context also applies to the API gateway login. The service
+// itself is left untouched, so it can prepare calls for many goroutines at once.
+func (c *CloudService) Prepare(ctx context.Context, request *Request) (*PreparedCall, error) {
+	// Overridden services are called directly, without going through the
+	// API gateway.
+	override, err := c.override()
 	if err != nil {
-		return fmt.Errorf("cannot authenticate for cloud service: %s", err)
+		return nil, err
 	}
+	if override != nil {
+		call, err := c.prepareAt(ctx, c.Client, c.withProtocol(request), []*url.URL{override})
+		if err != nil {
+			return nil, err
+		}
+		call.intercept(versionHeaderInterceptor)
+		c.setHeaders(call, request)
 
-	// Make any alterations based upon the namespace.
-	switch c.Namespace {
-	case "aggregators":
-		c.Name = strings.Join([]string{config.AggregatorDomainPrefix, c.Name}, "-")
+		return call, nil
 	}
 
-	cloudServiceUrl := domain.BuildCloudServiceUrl(c.GetApiGatewayUrl(request), c.Namespace, c.Name)
+	if c.Credentials.Email == "" || c.Credentials.Password == "" {
+		return nil, errors.New("cannot authenticate for cloud service: missing credentials")
+	}
 
-	// Build the resource URL.
-	resourceUrl := fmt.Sprintf("%s/%s", cloudServiceUrl, request.Resource)
+	request = c.withProtocol(request)
 
-	// Append the query string if we have any.
-	if len(request.Query) > 0 {
-		resourceUrl = fmt.Sprintf("%s?%s", resourceUrl, request.Query.Encode())
+	gatewayUrl, err := c.GetApiGatewayUrlE(request)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.getToken(ctx, gatewayUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot authenticate for cloud service: %w", err)
 	}
 
-	// Create the request.
-	var reqErr error
-	c.CurrentRequest, reqErr = http.NewRequest(request.Method, resourceUrl, request.Body)
-	if reqErr != nil {
-		return reqErr
+	gatewayUrls := c.GatewayUrls
+	if len(gatewayUrls) == 0 {
+		gatewayUrls = []string{gatewayUrl}
 	}
 
-	// Set the auth token header.
-	c.CurrentRequest.Header.Set(config.AuthHeader, token.PrepareForHttp())
+	// Build the resource URL through each gateway instance.
+	resourceUrls := make([]string, 0, len(gatewayUrls))
+	for _, gatewayUrl := range gatewayUrls {
+		cloudServiceUrl := domain.BuildCloudServiceUrl(gatewayUrl, c.Namespace, c.resolvedName())
+		resourceUrls = append(resourceUrls, fmt.Sprintf("%s/%s", cloudServiceUrl, request.Resource))
+	}
+
+	call, err := newPreparedCall(ctx, &c.Service, c.Client, request, resourceUrls)
+	if err != nil {
+		return nil, err
+	}
+
+	// The headers are set on the request, so it can be sent with another
+	// client, and again by the built-in interceptors on every attempt at
+	// the call, so interceptors of the service see and can override them.
+	call.HTTPRequest.Header.Set(config.AuthHeader, token.PrepareForHttp())
+	call.intercept(c.authInterceptor(gatewayUrl, token), versionHeaderInterceptor)
+	c.setHeaders(call, request)
+
+	return call, nil
+}
 
+// setHeaders - Add the version header and the headers of the request to a call.
+func (c *CloudService) setHeaders(call *PreparedCall, request *Request) {
 	// Add the version header to the request if applicable.
 	if c.Version != 0 {
-		c.CurrentRequest.Header.Set(config.ServiceVersionHeader, strconv.Itoa(c.Version))
+		call.HTTPRequest.Header.Set(config.ServiceVersionHeader, strconv.Itoa(c.Version))
 	}
 
 	// Add the headers.
 	for key, value := range request.Headers {
-		c.CurrentRequest.Header.Set(key, value)
+		call.HTTPRequest.Header.Set(key, value)
 	}
+}
 
-	return nil
+// authInterceptor - Built-in interceptor adding the auth token header. If the
+// service rejects the token, a new one is fetched from the API gateway and
+// the request is replayed once, and later attempts at the call use it.
+func (c *CloudService) authInterceptor(gatewayUrl string, token *models.Token) Interceptor {
+	var mu sync.Mutex
+
+	return func(invocation *Invocation, next Invoker) (*http.Response, error) {
+		mu.Lock()
+		current := token
+		mu.Unlock()
+
+		invocation.HTTPRequest.Header.Set(config.AuthHeader, current.PrepareForHttp())
+
+		resp, err := next(invocation)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+
+		// If we cannot get a new token, or cannot replay the request, hand
+		// back the original response.
+		replay, err := replayRequest(invocation.HTTPRequest)
+		if err != nil {
+			return resp, nil
+		}
+
+		ctx := invocation.HTTPRequest.Context()
+		c.getTokenCache().Invalidate(tokenCacheKey(gatewayUrl, c.Credentials), current)
+		fresh, err := c.getToken(ctx, gatewayUrl)
+		if err != nil {
+			return resp, nil
+		}
+
+		mu.Lock()
+		token = fresh
+		mu.Unlock()
+
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		replay.Header.Set(config.AuthHeader, fresh.PrepareForHttp())
+		replayed := *invocation
+		replayed.HTTPRequest = replay
+
+		return next(&replayed)
+	}
 }
 
 // Dial - Get the name of the service
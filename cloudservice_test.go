@@ -2,21 +2,29 @@ package microservicetransport
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"net/http/httptest"
 
 	"os"
+	"path/filepath"
 
 	"fmt"
 
 	"github.com/LUSHDigital/microservice-core-golang/format"
 	"github.com/LUSHDigital/microservice-core-golang/response"
 	"github.com/LUSHDigital/microservice-transport-golang/config"
+	transportErrors "github.com/LUSHDigital/microservice-transport-golang/errors"
 	"github.com/LUSHDigital/microservice-transport-golang/models"
 )
 
@@ -344,6 +352,342 @@ func TestCloudService_GetName(t *testing.T) {
 	}
 }
 
+func TestCloudService_DialContext(t *testing.T) {
+	// Start a HTTP server to act as a fake API gateway which hangs until the
+	// test is over.
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	os.Setenv("SOA_GATEWAY_URL", ts.URL)
+
+	service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "myservice", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := service.DialContext(ctx, &Request{
+		Method:   http.MethodGet,
+		Resource: "things",
+	})
+	if err == nil {
+		t.Fatalf("TestCloudService_DialContext: expected an error")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("TestCloudService_DialContext: expected login to be aborted, took %v", elapsed)
+	}
+}
+
+func TestCloudService_DialContext_sharedLogin(t *testing.T) {
+	// Start a HTTP server to act as a fake API gateway which hangs until the
+	// test is over.
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "myservice", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+	service.GatewayUrls = []string{ts.URL}
+	service.TokenCache = NewTokenCache()
+
+	// Start a login which holds on to the gateway.
+	go service.Prepare(context.Background(), &Request{Method: http.MethodGet, Resource: "things"})
+	time.Sleep(50 * time.Millisecond)
+
+	// A call waiting on that login is still aborted by its own context.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := service.Prepare(ctx, &Request{Method: http.MethodGet, Resource: "things"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TestCloudService_DialContext_sharedLogin: expected %v got %v", context.DeadlineExceeded, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("TestCloudService_DialContext_sharedLogin: expected the wait to be aborted, took %v", elapsed)
+	}
+}
+
+func TestCloudService_CallContext(t *testing.T) {
+	// Start a fake API gateway which logs in straight away but hangs on every
+	// other resource.
+	done := make(chan struct{})
+	ts := newFakeGateway(testGatewayToken, func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	})
+	defer ts.Close()
+	defer close(done)
+
+	os.Setenv("SOA_GATEWAY_URL", ts.URL)
+
+	service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "myservice", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := service.DialContext(ctx, &Request{
+		Method:   http.MethodGet,
+		Resource: "things",
+	})
+	if err != nil {
+		t.Fatalf("TestCloudService_CallContext: %s", err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err = service.Call()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("TestCloudService_CallContext: expected %v got %v", context.Canceled, err)
+	}
+}
+
+func TestCloudService_Dial_tokenCache(t *testing.T) {
+	ts := newFakeGateway(testGatewayToken, nil)
+	defer ts.Close()
+
+	os.Setenv("SOA_GATEWAY_URL", ts.URL)
+
+	credentials := &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	}
+	cache := NewTokenCache()
+
+	services := []*CloudService{
+		NewCloudService(DefaultHttpClient(), "master", "staging", "services", "myservice", credentials),
+		NewCloudService(DefaultHttpClient(), "master", "staging", "services", "otherservice", credentials),
+	}
+	for _, service := range services {
+		service.TokenCache = cache
+
+		for i := 0; i < 3; i++ {
+			err := service.Dial(&Request{
+				Method:   http.MethodGet,
+				Resource: "things",
+			})
+			if err != nil {
+				t.Fatalf("TestCloudService_Dial_tokenCache: %s", err)
+			}
+		}
+	}
+
+	if ts.Logins() != 1 {
+		t.Errorf("TestCloudService_Dial_tokenCache: expected %v got %v", 1, ts.Logins())
+	}
+}
+
+func TestCloudService_Call_reauthenticate(t *testing.T) {
+	tt := []struct {
+		name             string
+		acceptFreshToken bool
+		expectedStatus   int
+		expectedLogins   int
+		expectedCalls    int
+	}{
+		{
+			name:             "Fresh token accepted",
+			acceptFreshToken: true,
+			expectedStatus:   http.StatusOK,
+			expectedLogins:   1,
+			expectedCalls:    2,
+		},
+		{
+			name:             "Fresh token rejected",
+			acceptFreshToken: false,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedLogins:   1,
+			expectedCalls:    2,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int
+
+			// Start a fake API gateway, which only accepts the fresh token
+			// and echoes back the request body.
+			ts := newFakeGateway(&models.Token{Type: "random", Value: "fresh"}, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if !tc.acceptFreshToken || r.Header.Get(config.AuthHeader) != "Bearer fresh" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				io.Copy(w, r.Body)
+			})
+			defer ts.Close()
+
+			os.Setenv("SOA_GATEWAY_URL", ts.URL)
+
+			service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "myservice", &AuthCredentials{
+				Email:    "test@test.com",
+				Password: "1234",
+			})
+			service.TokenCache = NewTokenCache()
+
+			// Seed the cache with a token the service will reject.
+			service.TokenCache.Get(context.Background(), tokenCacheKey(ts.URL, service.Credentials), func() (*models.Token, error) {
+				return &models.Token{Type: "random", Value: "stale"}, nil
+			})
+
+			err := service.Dial(&Request{
+				Method:   http.MethodPost,
+				Resource: "things",
+				Body:     ioutil.NopCloser(bytes.NewBufferString(`{"foo":"bar"}`)),
+			})
+			if err != nil {
+				t.Fatalf("TestCloudService_Call_reauthenticate: %s: %s", tc.name, err)
+			}
+
+			resp, err := service.Call()
+			if err != nil {
+				t.Fatalf("TestCloudService_Call_reauthenticate: %s: %s", tc.name, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("TestCloudService_Call_reauthenticate: %s: expected status %v got %v", tc.name, tc.expectedStatus, resp.StatusCode)
+			}
+
+			if ts.Logins() != tc.expectedLogins {
+				t.Errorf("TestCloudService_Call_reauthenticate: %s: expected logins %v got %v", tc.name, tc.expectedLogins, ts.Logins())
+			}
+
+			if calls != tc.expectedCalls {
+				t.Errorf("TestCloudService_Call_reauthenticate: %s: expected calls %v got %v", tc.name, tc.expectedCalls, calls)
+			}
+
+			if tc.expectedStatus == http.StatusOK {
+				body, _ := ioutil.ReadAll(resp.Body)
+				if string(body) != `{"foo":"bar"}` {
+					t.Errorf("TestCloudService_Call_reauthenticate: %s: expected body %v got %v", tc.name, `{"foo":"bar"}`, string(body))
+				}
+			}
+		})
+	}
+}
+
+func TestCloudService_Prepare_concurrent(t *testing.T) {
+	// Start a fake API gateway, echoing back the path of every request but
+	// logins.
+	ts := newFakeGateway(testGatewayToken, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	})
+	defer ts.Close()
+
+	os.Setenv("SOA_GATEWAY_URL", ts.URL)
+
+	service := NewCloudService(DefaultHttpClient(), "master", "staging", "aggregators", "myaggregator", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+	service.TokenCache = NewTokenCache()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			resource := fmt.Sprintf("things/%d", i)
+			call, err := service.Prepare(context.Background(), &Request{
+				Method:   http.MethodGet,
+				Resource: resource,
+			})
+			if err != nil {
+				t.Errorf("TestCloudService_Prepare_concurrent: %s", err)
+				return
+			}
+
+			resp, err := call.Do(context.Background())
+			if err != nil {
+				t.Errorf("TestCloudService_Prepare_concurrent: %s", err)
+				return
+			}
+			defer resp.Body.Close()
+
+			expectedPath := "/aggregators/agg-myaggregator/" + resource
+			body, _ := ioutil.ReadAll(resp.Body)
+			if string(body) != expectedPath {
+				t.Errorf("TestCloudService_Prepare_concurrent: expected %v got %v", expectedPath, string(body))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if service.GetName() != "myaggregator" {
+		t.Errorf("TestCloudService_Prepare_concurrent: expected %v got %v", "myaggregator", service.GetName())
+	}
+}
+
+func TestCloudService_Dial_loginErrors(t *testing.T) {
+	tt := []struct {
+		name        string
+		status      int
+		body        string
+		expectedErr error
+	}{
+		{
+			name:        "Unauthorised",
+			status:      http.StatusUnauthorized,
+			body:        `{"status":"fail","code":401,"message":"unauthorised"}`,
+			expectedErr: transportErrors.LoginUnauthorisedError{},
+		},
+		{
+			name:        "Gateway broken",
+			status:      http.StatusInternalServerError,
+			body:        `{"status":"fail","code":500,"message":"broken"}`,
+			expectedErr: transportErrors.ErrServerError,
+		},
+		{
+			name:        "Gateway unavailable",
+			status:      http.StatusBadGateway,
+			body:        `<html>bad gateway</html>`,
+			expectedErr: transportErrors.ErrServerError,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				io.WriteString(w, tc.body)
+			}))
+			defer ts.Close()
+
+			os.Setenv("SOA_GATEWAY_URL", ts.URL)
+
+			service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "myservice", &AuthCredentials{
+				Email:    "test@test.com",
+				Password: "1234",
+			})
+			service.TokenCache = NewTokenCache()
+
+			err := service.Dial(&Request{
+				Method:   http.MethodGet,
+				Resource: "things",
+			})
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("TestCloudService_Dial_loginErrors: %s: expected %v got %v", tc.name, tc.expectedErr, err)
+			}
+		})
+	}
+}
+
 func TestCloudService_GetApiGatewayUrl(t *testing.T) {
 	tt := []struct {
 		name               string
@@ -402,6 +746,11 @@ func TestCloudService_GetApiGatewayUrl(t *testing.T) {
 			if actualGatewayUrl != tc.expectedGatewayUrl {
 				t.Errorf("TestCloudService_GetApiGatewayUrl: %s: expected %v got %v", tc.name, tc.expectedGatewayUrl, actualGatewayUrl)
 			}
+
+			actualGatewayUrl, err := myService.GetApiGatewayUrlE(myServiceThingsRequest)
+			if err != nil || actualGatewayUrl != tc.expectedGatewayUrl {
+				t.Errorf("TestCloudService_GetApiGatewayUrl: %s: expected %v got %v, %v", tc.name, tc.expectedGatewayUrl, actualGatewayUrl, err)
+			}
 		})
 	}
 }
@@ -787,3 +1136,136 @@ func ExampleCloudService_Call_headers() {
 		fmt.Println("response err: internal server error")
 	}
 }
+
+func TestCloudService_Dial_config(t *testing.T) {
+	ts := newFakeGateway(testGatewayToken, nil)
+	defer ts.Close()
+
+	cfg, err := config.Load(
+		config.WithEnv(func(string) (string, bool) { return "", false }),
+		config.WithGatewayUrl(ts.URL),
+	)
+	if err != nil {
+		t.Fatalf("TestCloudService_Dial_config: %s", err)
+	}
+
+	service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "myservice", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+	service.Config = cfg
+	service.TokenCache = NewTokenCache()
+
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+		t.Fatalf("TestCloudService_Dial_config: %s", err)
+	}
+
+	expectedUrl := ts.URL + "/services/myservice/things"
+	if service.CurrentRequest.URL.String() != expectedUrl {
+		t.Errorf("TestCloudService_Dial_config: expected %v got %v", expectedUrl, service.CurrentRequest.URL.String())
+	}
+
+	// A config which cannot build the gateway URL fails the dial.
+	service.Config = &config.Config{GatewayUri: config.DefaultGatewayUri}
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err == nil {
+		t.Errorf("TestCloudService_Dial_config: expected an error for an unconfigured gateway")
+	}
+}
+
+func TestCloudService_Dial_configFile(t *testing.T) {
+	ts := newFakeGateway(testGatewayToken, nil)
+	defer ts.Close()
+
+	// Add an environment through the config file named in the environment,
+	// without setting a config on the service.
+	file := filepath.Join(t.TempDir(), "config.json")
+	err := ioutil.WriteFile(file, []byte(fmt.Sprintf(`{"environments": {"qa2": {"gateway_url": %q}}}`, ts.URL)), 0644)
+	if err != nil {
+		t.Fatalf("TestCloudService_Dial_configFile: %s", err)
+	}
+
+	os.Setenv(config.FileEnv, file)
+	defer os.Unsetenv(config.FileEnv)
+	os.Unsetenv("SOA_GATEWAY_URL")
+
+	service := NewCloudService(DefaultHttpClient(), "master", "qa2", "services", "myservice", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+	service.TokenCache = NewTokenCache()
+
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+		t.Fatalf("TestCloudService_Dial_configFile: %s", err)
+	}
+
+	expectedUrl := ts.URL + "/services/myservice/things"
+	if service.CurrentRequest.URL.String() != expectedUrl {
+		t.Errorf("TestCloudService_Dial_configFile: expected %v got %v", expectedUrl, service.CurrentRequest.URL.String())
+	}
+}
+
+func TestCloudService_Dial_unknownEnvironment(t *testing.T) {
+	service := NewCloudService(DefaultHttpClient(), "master", "qa2", "services", "myservice", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+	service.Config = &config.Config{
+		ServiceDomain: "test.com",
+		GatewayUri:    "api-gateway",
+		Environments:  config.DefaultEnvironments(),
+	}
+
+	err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"})
+	if !errors.Is(err, config.ErrUnknownEnvironment) {
+		t.Errorf("TestCloudService_Dial_unknownEnvironment: expected %v got %v", config.ErrUnknownEnvironment, err)
+	}
+
+	if gatewayUrl := service.GetApiGatewayUrl(&Request{}); gatewayUrl != "" {
+		t.Errorf("TestCloudService_Dial_unknownEnvironment: expected no gateway url got %v", gatewayUrl)
+	}
+
+	if _, err := service.GetApiGatewayUrlE(&Request{}); !errors.Is(err, config.ErrUnknownEnvironment) {
+		t.Errorf("TestCloudService_Dial_unknownEnvironment: expected %v got %v", config.ErrUnknownEnvironment, err)
+	}
+}
+
+// testGatewayToken - Token handed out by fake API gateways in tests.
+var testGatewayToken = &models.Token{Type: "JWT", Value: "xxxx.xxxx.xxxx"}
+
+// fakeGateway - HTTP server acting as a fake API gateway, counting the
+// logins made with it.
+type fakeGateway struct {
+	*httptest.Server
+	logins int32
+}
+
+// newFakeGateway - Start a fake API gateway which logs in with the provided
+// token, and passes every other request on to the handler. Other requests
+// get an empty 200 response if the handler is nil.
+func newFakeGateway(token *models.Token, handler http.HandlerFunc) *fakeGateway {
+	gateway := &fakeGateway{}
+	gateway.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/login" {
+			if handler != nil {
+				handler(w, r)
+			}
+			return
+		}
+
+		atomic.AddInt32(&gateway.logins, 1)
+		resp := response.New(http.StatusOK, "", &response.Data{
+			Type: "consumer",
+			Content: models.Consumer{
+				Tokens: []*models.Token{token},
+			},
+		})
+		format.JSONResponseFormatter(w, resp)
+	}))
+
+	return gateway
+}
+
+// Logins - Get the number of logins made with the gateway.
+func (g *fakeGateway) Logins() int {
+	return int(atomic.LoadInt32(&g.logins))
+}
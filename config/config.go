@@ -20,6 +20,10 @@ const (
 	// between layers of middleware.
 	RequestKey = "request"
 
+	// RequestIDHeader - Name of the HTTP header carrying the ID of the request
+	// a call is made on behalf of, to correlate calls between services.
+	RequestIDHeader = "X-Request-ID"
+
 	// ServiceVersionHeader - Name of the HTTP header to use for service version.
 	ServiceVersionHeader = "x-service-version"
 
@@ -0,0 +1,398 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultGatewayUri - URI of the API gateway used if none is configured.
+	DefaultGatewayUri = "api-gateway"
+
+	// FileEnv - Name of the environment variable holding the path of the
+	// config file, if there is one.
+	FileEnv = "SOA_CONFIG_FILE"
+)
+
+// ErrUnknownEnvironment - Error returned for environments which have no
+// entry in the config.
+var ErrUnknownEnvironment = errors.New("unknown environment")
+
+// Config - Configuration of the service environment.
+type Config struct {
+	ServiceDomain string                 `json:"domain"`       // Top level domain of the service environment.
+	GatewayUri    string                 `json:"gateway_uri"`  // URI of the API gateway e.g. api-gateway.
+	GatewayUrl    string                 `json:"gateway_url"`  // Full URL of the API gateway, overriding the environments if set.
+	Environments  map[string]Environment `json:"environments"` // Where the API gateway of each CI environment is, by name.
+}
+
+// Environment - Where the API gateway of a CI environment is. Unless a full
+// URL is set, the gateway URL is built as protocol://uri{suffix}.domain.
+type Environment struct {
+	GatewayUrl    string `json:"gateway_url,omitempty"`    // Full URL of the API gateway of the environment.
+	GatewaySuffix string `json:"gateway_suffix,omitempty"` // Suffix of the gateway URI in the environment, e.g. -staging.
+	ServiceDomain string `json:"domain,omitempty"`         // Top level domain of the environment, the config domain if empty.
+}
+
+// DefaultEnvironments - Get the standard CI environments, where every
+// environment but prod has its name as the gateway suffix.
+func DefaultEnvironments() map[string]Environment {
+	return map[string]Environment{
+		"dev":     {GatewaySuffix: "-dev"},
+		"qa":      {GatewaySuffix: "-qa"},
+		"uat":     {GatewaySuffix: "-uat"},
+		"staging": {GatewaySuffix: "-staging"},
+		"prod":    {},
+	}
+}
+
+// FromEnv - Get the configuration set in the process environment, with the
+// default environments, without validating it. The config file is ignored;
+// use FromEnvAndFile to read it too.
+func FromEnv() *Config {
+	config := &Config{GatewayUri: DefaultGatewayUri, Environments: DefaultEnvironments()}
+	config.merge((&loader{lookupEnv: os.LookupEnv}).env())
+
+	return config
+}
+
+// FromEnvAndFile - Get the configuration set in the process environment and
+// the config file named by SOA_CONFIG_FILE, with the default environments,
+// without validating it. Environment variables take precedence over the
+// file. Each file is only read once, and problems reading it are returned
+// on every call.
+func FromEnvAndFile() (*Config, error) {
+	config := &Config{GatewayUri: DefaultGatewayUri, Environments: DefaultEnvironments()}
+
+	if path := os.Getenv(FileEnv); path != "" {
+		fileConfig, err := cachedFile(path)
+		if err != nil {
+			return nil, err
+		}
+		config.merge(fileConfig)
+	}
+
+	config.merge((&loader{lookupEnv: os.LookupEnv}).env())
+
+	return config, nil
+}
+
+// fileCache - Config files read by FromEnvAndFile, keyed by path.
+var fileCache = struct {
+	sync.Mutex
+	files map[string]cachedConfig
+}{files: make(map[string]cachedConfig)}
+
+// cachedConfig - The outcome of reading a config file.
+type cachedConfig struct {
+	config Config
+	err    error
+}
+
+// cachedFile - Read a config file, or get the outcome of reading it before.
+func cachedFile(path string) (Config, error) {
+	fileCache.Lock()
+	defer fileCache.Unlock()
+
+	cached, ok := fileCache.files[path]
+	if !ok {
+		cached.config, cached.err = loadFile(path)
+		fileCache.files[path] = cached
+	}
+
+	return cached.config, cached.err
+}
+
+// Option - Explicitly sets part of the configuration when loading it.
+type Option func(*loader)
+
+// loader - Sources to load the configuration from.
+type loader struct {
+	explicit  Config
+	file      string
+	lookupEnv func(key string) (string, bool)
+}
+
+// WithServiceDomain - Set the top level domain of the service environment.
+func WithServiceDomain(domain string) Option {
+	return func(l *loader) {
+		l.explicit.ServiceDomain = domain
+	}
+}
+
+// WithGatewayUri - Set the URI of the API gateway.
+func WithGatewayUri(uri string) Option {
+	return func(l *loader) {
+		l.explicit.GatewayUri = uri
+	}
+}
+
+// WithGatewayUrl - Set the full URL of the API gateway.
+func WithGatewayUrl(gatewayUrl string) Option {
+	return func(l *loader) {
+		l.explicit.GatewayUrl = gatewayUrl
+	}
+}
+
+// WithEnvironment - Set where the API gateway of a CI environment is.
+func WithEnvironment(name string, environment Environment) Option {
+	return func(l *loader) {
+		if l.explicit.Environments == nil {
+			l.explicit.Environments = make(map[string]Environment)
+		}
+		l.explicit.Environments[name] = environment
+	}
+}
+
+// WithFile - Load the config file at the path, rather than the one named by
+// the SOA_CONFIG_FILE environment variable.
+func WithFile(path string) Option {
+	return func(l *loader) {
+		l.file = path
+	}
+}
+
+// WithEnv - Look environment variables up with the provided function rather
+// than from the process environment.
+func WithEnv(lookup func(key string) (string, bool)) Option {
+	return func(l *loader) {
+		l.lookupEnv = lookup
+	}
+}
+
+// Load - Load the configuration. Values set by options take precedence over
+// environment variables, which take precedence over the config file, which
+// takes precedence over the defaults. All problems found with the
+// configuration are returned together as Errors.
+func Load(options ...Option) (*Config, error) {
+	l := &loader{lookupEnv: os.LookupEnv}
+	for _, option := range options {
+		option(l)
+	}
+
+	config := &Config{GatewayUri: DefaultGatewayUri, Environments: DefaultEnvironments()}
+	errs := Errors{}
+
+	if l.file == "" {
+		l.file, _ = l.lookupEnv(FileEnv)
+	}
+	if l.file != "" {
+		fileConfig, err := loadFile(l.file)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			config.merge(fileConfig)
+		}
+	}
+
+	config.merge(l.env())
+	config.merge(l.explicit)
+
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err.(Errors)...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return config, nil
+}
+
+// Validate - Check the configured values are well formed, returning every
+// problem found as Errors.
+func (c *Config) Validate() error {
+	errs := Errors{}
+
+	if c.ServiceDomain != "" && !isDomain(c.ServiceDomain) {
+		errs = append(errs, fmt.Errorf("invalid domain %q", c.ServiceDomain))
+	}
+
+	if c.GatewayUri != "" && !isDomain(c.GatewayUri) {
+		errs = append(errs, fmt.Errorf("invalid gateway uri %q", c.GatewayUri))
+	}
+
+	if c.GatewayUrl != "" {
+		if err := validateUrl(c.GatewayUrl); err != nil {
+			errs = append(errs, fmt.Errorf("invalid gateway url %w", err))
+		}
+	}
+
+	for _, name := range c.environmentNames() {
+		environment := c.Environments[name]
+
+		if environment.GatewayUrl != "" {
+			if err := validateUrl(environment.GatewayUrl); err != nil {
+				errs = append(errs, fmt.Errorf("invalid gateway url for environment %s %w", name, err))
+			}
+		}
+
+		if environment.ServiceDomain != "" && !isDomain(environment.ServiceDomain) {
+			errs = append(errs, fmt.Errorf("invalid domain for environment %s %q", name, environment.ServiceDomain))
+		}
+
+		if environment.GatewaySuffix != "" && !isDomain("x"+environment.GatewaySuffix) {
+			errs = append(errs, fmt.Errorf("invalid gateway suffix for environment %s %q", name, environment.GatewaySuffix))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// GetGatewayUrl - Get the URL of the API gateway for a CI environment,
+// reached with the provided protocol. Environments with no entry in the
+// config return an error wrapping ErrUnknownEnvironment.
+func (c *Config) GetGatewayUrl(protocol, environment string) (string, error) {
+	if c.GatewayUrl != "" {
+		return c.GatewayUrl, nil
+	}
+
+	env, ok := c.Environments[environment]
+	if !ok {
+		return "", fmt.Errorf("cannot build api gateway url: %w %q, expected one of %s",
+			ErrUnknownEnvironment, environment, strings.Join(c.environmentNames(), ", "))
+	}
+
+	if env.GatewayUrl != "" {
+		return env.GatewayUrl, nil
+	}
+
+	domain := env.ServiceDomain
+	if domain == "" {
+		domain = c.ServiceDomain
+	}
+	if domain == "" {
+		return "", fmt.Errorf("cannot build api gateway url: set a gateway url or a domain")
+	}
+
+	uri := c.GatewayUri
+	if uri == "" {
+		uri = DefaultGatewayUri
+	}
+
+	return fmt.Sprintf("%s://%s%s.%s", protocol, uri, env.GatewaySuffix, domain), nil
+}
+
+// environmentNames - Get the names of the configured environments, in order.
+func (c *Config) environmentNames() []string {
+	names := make([]string, 0, len(c.Environments))
+	for name := range c.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// merge - Override the configuration with the values set in another.
+func (c *Config) merge(other Config) {
+	if other.ServiceDomain != "" {
+		c.ServiceDomain = other.ServiceDomain
+	}
+	if other.GatewayUri != "" {
+		c.GatewayUri = other.GatewayUri
+	}
+	if other.GatewayUrl != "" {
+		c.GatewayUrl = other.GatewayUrl
+	}
+
+	for name, environment := range other.Environments {
+		if c.Environments == nil {
+			c.Environments = make(map[string]Environment)
+		}
+		c.Environments[name] = environment
+	}
+}
+
+// env - Get the configuration set in the environment.
+func (l *loader) env() Config {
+	config := Config{}
+	config.ServiceDomain, _ = l.lookupEnv("SOA_DOMAIN")
+	config.GatewayUri, _ = l.lookupEnv("SOA_GATEWAY_URI")
+	config.GatewayUrl, _ = l.lookupEnv("SOA_GATEWAY_URL")
+
+	return config
+}
+
+// loadFile - Load the configuration from a JSON file.
+func loadFile(path string) (Config, error) {
+	config := Config{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return config, fmt.Errorf("cannot open config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("cannot decode config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// validateUrl - Check a URL is an absolute http or https URL.
+func validateUrl(rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%q: %w", rawUrl, err)
+	case u.Scheme != ProtocolHTTP && u.Scheme != ProtocolHTTPS:
+		return fmt.Errorf("%q: scheme must be http or https", rawUrl)
+	case u.Host == "":
+		return fmt.Errorf("%q: missing host", rawUrl)
+	}
+
+	return nil
+}
+
+// isDomain - Check whether a value is a valid DNS name.
+func isDomain(value string) bool {
+	if len(value) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(value, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range strings.ToLower(label) {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Errors - Problems found with a configuration, reported together.
+type Errors []error
+
+// Error - Get the problems as a single message.
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return "invalid config: " + strings.Join(messages, "; ")
+}
+
+// Unwrap - Get the individual problems.
+func (e Errors) Unwrap() []error {
+	return e
+}
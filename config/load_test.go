@@ -0,0 +1,264 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("TestLoad: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(file, []byte(`{"domain": "file.example.com", "gateway_uri": "file-gateway",
+		"environments": {"perf": {"gateway_suffix": "-perf"}}}`), 0644)
+	if err != nil {
+		t.Fatalf("TestLoad: %s", err)
+	}
+
+	tt := []struct {
+		name     string
+		env      map[string]string
+		options  []Option
+		expected Config
+	}{
+		{
+			name:     "Defaults",
+			expected: Config{GatewayUri: DefaultGatewayUri},
+		},
+		{
+			name: "File named by env",
+			env:  map[string]string{FileEnv: file},
+			expected: Config{
+				ServiceDomain: "file.example.com",
+				GatewayUri:    "file-gateway",
+				Environments:  map[string]Environment{"perf": {GatewaySuffix: "-perf"}},
+			},
+		},
+		{
+			name:    "Env over file",
+			env:     map[string]string{"SOA_DOMAIN": "env.example.com"},
+			options: []Option{WithFile(file)},
+			expected: Config{
+				ServiceDomain: "env.example.com",
+				GatewayUri:    "file-gateway",
+				Environments:  map[string]Environment{"perf": {GatewaySuffix: "-perf"}},
+			},
+		},
+		{
+			name: "Options over env",
+			env:  map[string]string{"SOA_DOMAIN": "env.example.com", "SOA_GATEWAY_URL": "http://env-gateway"},
+			options: []Option{
+				WithServiceDomain("option.example.com"),
+				WithGatewayUri("option-gateway"),
+				WithGatewayUrl("https://option-gateway.example.com"),
+				WithEnvironment("staging", Environment{GatewayUrl: "https://staging-gateway.example.com"}),
+			},
+			expected: Config{
+				ServiceDomain: "option.example.com",
+				GatewayUri:    "option-gateway",
+				GatewayUrl:    "https://option-gateway.example.com",
+				Environments:  map[string]Environment{"staging": {GatewayUrl: "https://staging-gateway.example.com"}},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			options := append([]Option{WithEnv(func(key string) (string, bool) {
+				value, ok := tc.env[key]
+				return value, ok
+			})}, tc.options...)
+
+			config, err := Load(options...)
+			if err != nil {
+				t.Fatalf("TestLoad: %s: %s", tc.name, err)
+			}
+
+			// Configured environments are added to the defaults.
+			environments := DefaultEnvironments()
+			for name, environment := range tc.expected.Environments {
+				environments[name] = environment
+			}
+			tc.expected.Environments = environments
+
+			if !reflect.DeepEqual(*config, tc.expected) {
+				t.Errorf("TestLoad: %s: expected %+v got %+v", tc.name, tc.expected, *config)
+			}
+		})
+	}
+}
+
+func TestLoad_invalid(t *testing.T) {
+	env := func(key string) (string, bool) {
+		return map[string]string{FileEnv: "/does/not/exist.json"}[key], key == FileEnv
+	}
+
+	_, err := Load(
+		WithEnv(env),
+		WithServiceDomain("example..com"),
+		WithGatewayUri("-gateway"),
+		WithGatewayUrl("ftp://gateway.example.com"),
+		WithEnvironment("perf", Environment{GatewaySuffix: "_perf", ServiceDomain: "perf..example.com"}),
+	)
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("TestLoad_invalid: expected Errors got %v", err)
+	}
+
+	expected := []string{
+		"cannot open config file",
+		`invalid domain "example..com"`,
+		`invalid gateway uri "-gateway"`,
+		`invalid gateway url "ftp://gateway.example.com": scheme must be http or https`,
+		`invalid domain for environment perf "perf..example.com"`,
+		`invalid gateway suffix for environment perf "_perf"`,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("TestLoad_invalid: expected %d errors got %v", len(expected), errs)
+	}
+	for i := range expected {
+		if !strings.HasPrefix(errs[i].Error(), expected[i]) {
+			t.Errorf("TestLoad_invalid: expected %v got %v", expected[i], errs[i])
+		}
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("TestLoad_invalid: expected the missing file to be matchable")
+	}
+}
+
+func TestFromEnvAndFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("TestFromEnvAndFile: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(file, []byte(`{"domain": "file.example.com", "environments": {"perf": {"gateway_suffix": "-perf"}}}`), 0644)
+	if err != nil {
+		t.Fatalf("TestFromEnvAndFile: %s", err)
+	}
+
+	os.Setenv(FileEnv, file)
+	os.Setenv("SOA_GATEWAY_URI", "env-gateway")
+	defer os.Unsetenv(FileEnv)
+	defer os.Unsetenv("SOA_GATEWAY_URI")
+
+	config, err := FromEnvAndFile()
+	if err != nil {
+		t.Fatalf("TestFromEnvAndFile: %s", err)
+	}
+
+	gatewayUrl, err := config.GetGatewayUrl(ProtocolHTTPS, "perf")
+	if err != nil {
+		t.Fatalf("TestFromEnvAndFile: %s", err)
+	}
+	if expected := "https://env-gateway-perf.file.example.com"; gatewayUrl != expected {
+		t.Errorf("TestFromEnvAndFile: expected %v got %v", expected, gatewayUrl)
+	}
+
+	// The file is only read once.
+	os.Remove(file)
+	if _, err := FromEnvAndFile(); err != nil {
+		t.Errorf("TestFromEnvAndFile: expected the cached file got %v", err)
+	}
+
+	os.Setenv(FileEnv, filepath.Join(dir, "missing.json"))
+	if _, err := FromEnvAndFile(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("TestFromEnvAndFile: expected %v got %v", os.ErrNotExist, err)
+	}
+}
+
+func TestConfig_GetGatewayUrl(t *testing.T) {
+	tt := []struct {
+		name        string
+		config      Config
+		environment string
+		expectedUrl string
+		expectedErr bool
+	}{
+		{
+			name:        "Full URL",
+			config:      Config{ServiceDomain: "example.com", GatewayUrl: "https://gateway.example.com"},
+			environment: "staging",
+			expectedUrl: "https://gateway.example.com",
+		},
+		{
+			name:        "Staging",
+			config:      Config{ServiceDomain: "example.com", GatewayUri: "api-gateway", Environments: DefaultEnvironments()},
+			environment: "staging",
+			expectedUrl: "http://api-gateway-staging.example.com",
+		},
+		{
+			name:        "QA",
+			config:      Config{ServiceDomain: "example.com", GatewayUri: "api-gateway", Environments: DefaultEnvironments()},
+			environment: "qa",
+			expectedUrl: "http://api-gateway-qa.example.com",
+		},
+		{
+			name:        "Production",
+			config:      Config{ServiceDomain: "example.com", GatewayUri: "api-gateway", Environments: DefaultEnvironments()},
+			environment: "prod",
+			expectedUrl: "http://api-gateway.example.com",
+		},
+		{
+			name: "Custom environments",
+			config: Config{ServiceDomain: "example.com", GatewayUri: "api-gateway", Environments: map[string]Environment{
+				"perf": {GatewaySuffix: "-perf", ServiceDomain: "perf.example.com"},
+				"demo": {GatewayUrl: "https://demo.example.com"},
+			}},
+			environment: "perf",
+			expectedUrl: "http://api-gateway-perf.perf.example.com",
+		},
+		{
+			name:        "Unknown environment",
+			config:      Config{ServiceDomain: "example.com", GatewayUri: "api-gateway", Environments: DefaultEnvironments()},
+			environment: "qa2",
+			expectedErr: true,
+		},
+		{
+			name:        "Unconfigured",
+			config:      Config{GatewayUri: "api-gateway", Environments: DefaultEnvironments()},
+			environment: "staging",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			gatewayUrl, err := tc.config.GetGatewayUrl(ProtocolHTTP, tc.environment)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("TestConfig_GetGatewayUrl: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+
+			if gatewayUrl != tc.expectedUrl {
+				t.Errorf("TestConfig_GetGatewayUrl: %s: expected %v got %v", tc.name, tc.expectedUrl, gatewayUrl)
+			}
+		})
+	}
+}
+
+func TestConfig_GetGatewayUrl_unknownEnvironment(t *testing.T) {
+	config := Config{ServiceDomain: "example.com", Environments: DefaultEnvironments()}
+
+	_, err := config.GetGatewayUrl(ProtocolHTTP, "production")
+	if !errors.Is(err, ErrUnknownEnvironment) {
+		t.Fatalf("TestConfig_GetGatewayUrl_unknownEnvironment: expected %v got %v", ErrUnknownEnvironment, err)
+	}
+
+	expected := `cannot build api gateway url: unknown environment "production", expected one of dev, prod, qa, staging, uat`
+	if err.Error() != expected {
+		t.Errorf("TestConfig_GetGatewayUrl_unknownEnvironment: expected %v got %v", expected, err)
+	}
+}
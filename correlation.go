@@ -0,0 +1,88 @@
+package microservicetransport
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"github.com/LUSHDigital/microservice-transport-golang/config"
+)
+
+// DefaultForwardedHeaders - Inbound headers forwarded on calls by services
+// which do not set their own, along with the request ID.
+var DefaultForwardedHeaders = []string{"Accept-Language", "X-Locale", "X-Market"}
+
+// RequestIDMiddleware - Make the inbound request available to calls made
+// while handling it, so its request ID and forwarded headers are passed on.
+// Requests without an X-Request-ID header are given a new one.
+//
+//	http.Handle("/", RequestIDMiddleware(handler))
+//
+// The inbound request is kept in the context under config.RequestKey.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(config.RequestIDHeader) == "" {
+			requestID, err := newRequestID()
+			if err == nil {
+				r.Header.Set(config.RequestIDHeader, requestID)
+			}
+		}
+
+		// Echo the request ID back, so callers can correlate the response.
+		w.Header().Set(config.RequestIDHeader, r.Header.Get(config.RequestIDHeader))
+
+		ctx := context.WithValue(r.Context(), config.RequestKey, r)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext - Get the ID of the inbound request kept in a context
+// by RequestIDMiddleware, or an empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	inbound := inboundRequest(ctx)
+	if inbound == nil {
+		return ""
+	}
+
+	return inbound.Header.Get(config.RequestIDHeader)
+}
+
+// inboundRequest - Get the inbound request kept in a context, if any.
+func inboundRequest(ctx context.Context) *http.Request {
+	inbound, _ := ctx.Value(config.RequestKey).(*http.Request)
+	return inbound
+}
+
+// forwardHeaders - Copy the request ID and the forwarded headers of the
+// inbound request kept in a context onto an outgoing request.
+func forwardHeaders(ctx context.Context, req *http.Request, forwarded []string) {
+	inbound := inboundRequest(ctx)
+	if inbound == nil {
+		return
+	}
+
+	if forwarded == nil {
+		forwarded = DefaultForwardedHeaders
+	}
+
+	for _, key := range append([]string{config.RequestIDHeader}, forwarded...) {
+		if value := inbound.Header.Get(key); value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+}
+
+// newRequestID - Generate a random request ID, formatted as a UUID.
+func newRequestID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("cannot generate request id: %w", err)
+	}
+
+	// Mark the ID as a version 4, variant 1 UUID.
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16]), nil
+}
@@ -0,0 +1,138 @@
+package microservicetransport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/LUSHDigital/microservice-transport-golang/config"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	tt := []struct {
+		name              string
+		requestID         string
+		expectedRequestID string
+	}{
+		{
+			name:              "Inbound request ID",
+			requestID:         "abc-123",
+			expectedRequestID: "abc-123",
+		},
+		{
+			name: "Generated request ID",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var requestID string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestID = RequestIDFromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.requestID != "" {
+				r.Header.Set(config.RequestIDHeader, tc.requestID)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if tc.expectedRequestID != "" && requestID != tc.expectedRequestID {
+				t.Errorf("TestRequestIDMiddleware: %s: expected %v got %v", tc.name, tc.expectedRequestID, requestID)
+			}
+			if tc.expectedRequestID == "" && !uuidPattern.MatchString(requestID) {
+				t.Errorf("TestRequestIDMiddleware: %s: expected a generated id got %v", tc.name, requestID)
+			}
+
+			if w.Header().Get(config.RequestIDHeader) != requestID {
+				t.Errorf("TestRequestIDMiddleware: %s: expected response id %v got %v", tc.name, requestID, w.Header().Get(config.RequestIDHeader))
+			}
+		})
+	}
+}
+
+func TestService_Dial_forwardHeaders(t *testing.T) {
+	tt := []struct {
+		name            string
+		forwardHeaders  []string
+		requestHeaders  map[string]string
+		expectedHeaders map[string]string
+	}{
+		{
+			name: "Default forwarded headers",
+			expectedHeaders: map[string]string{
+				config.RequestIDHeader: "abc-123",
+				"Accept-Language":      "en-GB",
+				"X-Market":             "uk",
+				"Cookie":               "",
+			},
+		},
+		{
+			name:           "Own forwarded headers",
+			forwardHeaders: []string{"Cookie"},
+			expectedHeaders: map[string]string{
+				config.RequestIDHeader: "abc-123",
+				"Accept-Language":      "",
+				"Cookie":               "session=secret",
+			},
+		},
+		{
+			name:           "Request headers take precedence",
+			requestHeaders: map[string]string{"X-Market": "fr"},
+			expectedHeaders: map[string]string{
+				config.RequestIDHeader: "abc-123",
+				"X-Market":             "fr",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Start a HTTP server to act as the service, capturing the
+			// headers it receives.
+			var received http.Header
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header
+			}))
+			defer ts.Close()
+
+			service := NewService(redirectClient(ts), "master", "staging", "services", "myservice")
+			service.ForwardHeaders = tc.forwardHeaders
+
+			// Call the service while handling an inbound request.
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err := service.DialContext(r.Context(), &Request{
+					Method:   http.MethodGet,
+					Resource: "things",
+					Headers:  tc.requestHeaders,
+				})
+				if err != nil {
+					t.Fatalf("TestService_Dial_forwardHeaders: %s: %s", tc.name, err)
+				}
+
+				resp, err := service.Call()
+				if err != nil {
+					t.Fatalf("TestService_Dial_forwardHeaders: %s: %s", tc.name, err)
+				}
+				resp.Body.Close()
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(config.RequestIDHeader, "abc-123")
+			r.Header.Set("Accept-Language", "en-GB")
+			r.Header.Set("X-Market", "uk")
+			r.Header.Set("Cookie", "session=secret")
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			for key, value := range tc.expectedHeaders {
+				if received.Get(key) != value {
+					t.Errorf("TestService_Dial_forwardHeaders: %s: expected %s %q got %q", tc.name, key, value, received.Get(key))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package microservicetransport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/LUSHDigital/microservice-core-golang/response"
+	transportErrors "github.com/LUSHDigital/microservice-transport-golang/errors"
+)
+
+// DecodeResponse - Decode a response in the standard envelope and extract the
+// data under key into dst, closing the response body. Extraction is skipped if
+// dst is nil. An error status returns a ServiceError.
+func DecodeResponse(resp *http.Response, key string, dst interface{}) (*response.Response, error) {
+	return decodeResponse(resp, "", key, dst)
+}
+
+// DecodePaginatedResponse - Decode a response in the paginated envelope and
+// extract the data under key into dst, closing the response body. Extraction is
+// skipped if dst is nil. An error status returns a ServiceError.
+func DecodePaginatedResponse(resp *http.Response, key string, dst interface{}) (*response.PaginatedResponse, error) {
+	return decodePaginatedResponse(resp, "", key, dst)
+}
+
+// decodeResponse - Decode a response in the standard envelope from the named
+// service.
+func decodeResponse(resp *http.Response, service, key string, dst interface{}) (*response.Response, error) {
+	serviceResponse := &response.Response{}
+
+	decoded, err := decodeBody(resp, service, serviceResponse, &serviceResponse.Status)
+	if !decoded {
+		serviceResponse = nil
+	}
+	if err != nil || dst == nil {
+		return serviceResponse, err
+	}
+
+	return serviceResponse, extractData(serviceResponse.Data, key, dst)
+}
+
+// decodePaginatedResponse - Decode a response in the paginated envelope from
+// the named service.
+func decodePaginatedResponse(resp *http.Response, service, key string, dst interface{}) (*response.PaginatedResponse, error) {
+	serviceResponse := &response.PaginatedResponse{}
+
+	decoded, err := decodeBody(resp, service, serviceResponse, &serviceResponse.Status)
+	if !decoded {
+		serviceResponse = nil
+	}
+	if err != nil || dst == nil {
+		return serviceResponse, err
+	}
+
+	return serviceResponse, extractData(serviceResponse.Data, key, dst)
+}
+
+// decodeBody - Read and close the response body, decoding it into envelope.
+// Error status codes, and envelopes whose status is not ok, return a
+// ServiceError.
+func decodeBody(resp *http.Response, service string, envelope interface{}, status *string) (bool, error) {
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("cannot read response: %w", err)
+	}
+
+	decodeErr := json.Unmarshal(body, envelope)
+	decoded := decodeErr == nil
+
+	if resp.StatusCode >= http.StatusBadRequest || (decoded && *status != response.StatusOk) {
+		return decoded, transportErrors.NewServiceError(service, resp, body)
+	}
+
+	if !decoded {
+		return false, fmt.Errorf("cannot decode response (%d): %w", resp.StatusCode, decodeErr)
+	}
+
+	return true, nil
+}
+
+// CallAndDecode - Do the current request of a transport and decode the
+// response as DecodeResponse does.
+func CallAndDecode(ctx context.Context, t Transport, key string, dst interface{}) (*response.Response, error) {
+	resp, err := t.CallContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeResponse(resp, t.GetName(), key, dst)
+}
+
+// CallAndDecodePaginated - Do the current request of a transport and decode
+// the response as DecodePaginatedResponse does.
+func CallAndDecodePaginated(ctx context.Context, t Transport, key string, dst interface{}) (*response.PaginatedResponse, error) {
+	resp, err := t.CallContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodePaginatedResponse(resp, t.GetName(), key, dst)
+}
+
+// Fetch - Do the prepared call and decode the response as DecodeResponse does.
+func (p *PreparedCall) Fetch(ctx context.Context, key string, dst interface{}) (*response.Response, error) {
+	resp, err := p.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeResponse(resp, p.service.GetName(), key, dst)
+}
+
+// FetchPaginated - Do the prepared call and decode the response as
+// DecodePaginatedResponse does.
+func (p *PreparedCall) FetchPaginated(ctx context.Context, key string, dst interface{}) (*response.PaginatedResponse, error) {
+	resp, err := p.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodePaginatedResponse(resp, p.service.GetName(), key, dst)
+}
+
+// extractData - Extract the data under key into dst.
+//
+// Unlike ExtractData on the response types, this also handles data holding
+// more than one key, and reports missing keys and undecodable data.
+func extractData(data *response.Data, key string, dst interface{}) error {
+	if data == nil {
+		return fmt.Errorf("cannot extract %s: response has no data", key)
+	}
+
+	var value interface{}
+	if data.Valid() {
+		// The data held a single collection.
+		if data.Type != key {
+			return fmt.Errorf("cannot extract %s: response holds %s", key, data.Type)
+		}
+		value = data.Content
+	} else {
+		content, ok := data.Content.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot extract %s: response data is not an object", key)
+		}
+
+		if value, ok = content[key]; !ok {
+			return fmt.Errorf("cannot extract %s: key not in response data", key)
+		}
+	}
+
+	rawJSON, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cannot extract %s: %s", key, err)
+	}
+
+	if err := json.Unmarshal(rawJSON, dst); err != nil {
+		return fmt.Errorf("cannot extract %s: %s", key, err)
+	}
+
+	return nil
+}
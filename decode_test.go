@@ -0,0 +1,175 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	transportErrors "github.com/LUSHDigital/microservice-transport-golang/errors"
+)
+
+type thing struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDecodeResponse(t *testing.T) {
+	tt := []struct {
+		name           string
+		status         int
+		body           string
+		key            string
+		expectedThings []thing
+		expectedErr    error
+		expectedKind   transportErrors.StatusClass
+		expectedCode   int
+	}{
+		{
+			name:           "Single collection",
+			status:         http.StatusOK,
+			body:           `{"status":"ok","code":200,"message":"","data":{"things":[{"id":1,"name":"foo"},{"id":2,"name":"bar"}]}}`,
+			key:            "things",
+			expectedThings: []thing{{ID: 1, Name: "foo"}, {ID: 2, Name: "bar"}},
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name:           "Several collections",
+			status:         http.StatusOK,
+			body:           `{"status":"ok","code":200,"message":"","data":{"things":[{"id":1,"name":"foo"}],"others":[{"id":2}]}}`,
+			key:            "things",
+			expectedThings: []thing{{ID: 1, Name: "foo"}},
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name:         "Missing key",
+			status:       http.StatusOK,
+			body:         `{"status":"ok","code":200,"message":"","data":{"others":[{"id":2}]}}`,
+			key:          "things",
+			expectedErr:  errors.New("cannot extract things: response holds others"),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Failed status",
+			status:       http.StatusNotFound,
+			body:         `{"status":"fail","code":404,"message":"thing not found"}`,
+			key:          "things",
+			expectedErr:  errors.New("404 Not Found: thing not found"),
+			expectedKind: transportErrors.ErrNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Failed envelope",
+			status:       http.StatusOK,
+			body:         `{"status":"fail","code":422,"message":"invalid thing"}`,
+			key:          "things",
+			expectedErr:  errors.New("200 OK: invalid thing"),
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Error status without envelope",
+			status:       http.StatusBadGateway,
+			body:         `<html>bad gateway</html>`,
+			key:          "things",
+			expectedErr:  errors.New("502 Bad Gateway"),
+			expectedKind: transportErrors.ErrServerError,
+		},
+		{
+			name:        "Not JSON",
+			status:      http.StatusOK,
+			body:        `<html>ok</html>`,
+			key:         "things",
+			expectedErr: errors.New("cannot decode response (200): invalid character '<' looking for beginning of value"),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			body := &closeRecorder{Reader: strings.NewReader(tc.body)}
+			resp := &http.Response{StatusCode: tc.status, Body: body}
+
+			var things []thing
+			serviceResponse, err := DecodeResponse(resp, tc.key, &things)
+			if fmt.Sprint(err) != fmt.Sprint(tc.expectedErr) {
+				t.Errorf("TestDecodeResponse: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+
+			if tc.expectedKind != 0 && !errors.Is(err, tc.expectedKind) {
+				t.Errorf("TestDecodeResponse: %s: expected %v got %v", tc.name, tc.expectedKind, err)
+			}
+
+			if !body.closed {
+				t.Errorf("TestDecodeResponse: %s: expected the body to be closed", tc.name)
+			}
+
+			if tc.expectedCode != 0 && serviceResponse.Code != tc.expectedCode {
+				t.Errorf("TestDecodeResponse: %s: expected code %v got %v", tc.name, tc.expectedCode, serviceResponse.Code)
+			}
+
+			if !reflect.DeepEqual(things, tc.expectedThings) {
+				t.Errorf("TestDecodeResponse: %s: expected %v got %v", tc.name, tc.expectedThings, things)
+			}
+		})
+	}
+}
+
+func TestDecodePaginatedResponse(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body: ioutil.NopCloser(strings.NewReader(`{"status":"ok","code":200,"message":"","data":{"things":[{"id":1,"name":"foo"}]},` +
+			`"pagination":{"total":3,"per_page":1,"current_page":1,"last_page":3,"next_page":2,"prev_page":null}}`)),
+	}
+
+	var things []thing
+	serviceResponse, err := DecodePaginatedResponse(resp, "things", &things)
+	if err != nil {
+		t.Fatalf("TestDecodePaginatedResponse: %s", err)
+	}
+
+	if len(things) != 1 || things[0].Name != "foo" {
+		t.Errorf("TestDecodePaginatedResponse: expected %v got %v", []thing{{ID: 1, Name: "foo"}}, things)
+	}
+
+	if serviceResponse.Pagination.LastPage != 3 || *serviceResponse.Pagination.NextPage != 2 {
+		t.Errorf("TestDecodePaginatedResponse: unexpected pagination %+v", serviceResponse.Pagination)
+	}
+}
+
+func TestCallAndDecode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"status":"ok","code":200,"message":"","data":{"thing":{"id":1,"name":"foo"}}}`)
+	}))
+	defer ts.Close()
+
+	service := NewService(redirectClient(ts), "master", "staging", "services", "myservice")
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things/1"}); err != nil {
+		t.Fatalf("TestCallAndDecode: %s", err)
+	}
+
+	var result thing
+	if _, err := CallAndDecode(context.Background(), service, "thing", &result); err != nil {
+		t.Fatalf("TestCallAndDecode: %s", err)
+	}
+
+	if result != (thing{ID: 1, Name: "foo"}) {
+		t.Errorf("TestCallAndDecode: expected %v got %v", thing{ID: 1, Name: "foo"}, result)
+	}
+}
+
+// closeRecorder - Response body which records whether it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+// Close - Record the body being closed.
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
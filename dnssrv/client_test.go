@@ -0,0 +1,131 @@
+package dnssrv_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/dnssrv"
+	"github.com/LUSHDigital/microservice-transport-golang/dnssrv/dnssrvtest"
+)
+
+func TestClient_LookupSRV(t *testing.T) {
+	server, err := dnssrvtest.NewServer(map[string][]dnssrv.Record{
+		"orders.services": {
+			{Target: "orders-0.services", Port: 8081, Priority: 1, Weight: 10, TTL: 30 * time.Second},
+			{Target: "orders-1.services", Port: 8082, Priority: 2, Weight: 5, TTL: 60 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("TestClient_LookupSRV: %s", err)
+	}
+	defer server.Close()
+
+	client := &dnssrv.Client{Server: server.Addr}
+
+	records, err := client.LookupSRV(context.Background(), "orders.services")
+	if err != nil {
+		t.Fatalf("TestClient_LookupSRV: %s", err)
+	}
+
+	expected := []dnssrv.Record{
+		{Target: "orders-0.services", Port: 8081, Priority: 1, Weight: 10, TTL: 30 * time.Second},
+		{Target: "orders-1.services", Port: 8082, Priority: 2, Weight: 5, TTL: 60 * time.Second},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("TestClient_LookupSRV: expected %v got %v", expected, records)
+	}
+	for i := range expected {
+		if records[i] != expected[i] {
+			t.Errorf("TestClient_LookupSRV: expected %v got %v", expected[i], records[i])
+		}
+	}
+
+	if _, err := client.LookupSRV(context.Background(), "payments.services"); !errors.Is(err, dnssrv.ErrNotFound) {
+		t.Errorf("TestClient_LookupSRV: expected %v got %v", dnssrv.ErrNotFound, err)
+	}
+}
+
+func TestClient_LookupSRV_search(t *testing.T) {
+	// Only the name qualified with the cluster domain has records.
+	server, err := dnssrvtest.NewServer(map[string][]dnssrv.Record{
+		"orders-master-staging.orders.svc.cluster.local": {
+			{Target: "orders-0.services", Port: 8081, TTL: 30 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("TestClient_LookupSRV_search: %s", err)
+	}
+	defer server.Close()
+
+	tt := []struct {
+		name            string
+		lookup          string
+		ndots           int
+		expectedQueries int
+		expectedErr     error
+	}{
+		{
+			name:            "Relative name",
+			lookup:          "orders-master-staging.orders",
+			ndots:           5,
+			expectedQueries: 2,
+		},
+		{
+			name:            "Tried as it is first",
+			lookup:          "orders-master-staging.orders",
+			ndots:           1,
+			expectedQueries: 3,
+		},
+		{
+			name:            "Absolute name",
+			lookup:          "orders-master-staging.orders.",
+			ndots:           5,
+			expectedQueries: 1,
+			expectedErr:     dnssrv.ErrNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &dnssrv.Client{
+				Server: server.Addr,
+				Search: []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+				NDots:  tc.ndots,
+			}
+
+			before := server.Queries()
+			records, err := client.LookupSRV(context.Background(), tc.lookup)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("TestClient_LookupSRV_search: %s: expected %v got %v", tc.name, tc.expectedErr, err)
+			}
+			if err == nil && (len(records) != 1 || records[0].Port != 8081) {
+				t.Errorf("TestClient_LookupSRV_search: %s: expected port 8081 got %v", tc.name, records)
+			}
+
+			if queries := server.Queries() - before; queries != tc.expectedQueries {
+				t.Errorf("TestClient_LookupSRV_search: %s: expected %d queries got %d", tc.name, tc.expectedQueries, queries)
+			}
+		})
+	}
+}
+
+func TestClient_LookupSRV_timeout(t *testing.T) {
+	server, err := dnssrvtest.NewServer(nil)
+	if err != nil {
+		t.Fatalf("TestClient_LookupSRV_timeout: %s", err)
+	}
+	server.Close()
+
+	client := &dnssrv.Client{Server: server.Addr, Timeout: 100 * time.Millisecond}
+
+	start := time.Now()
+	if _, err := client.LookupSRV(context.Background(), "orders.services"); err == nil {
+		t.Errorf("TestClient_LookupSRV_timeout: expected an error")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("TestClient_LookupSRV_timeout: lookup took %s", elapsed)
+	}
+}
@@ -0,0 +1,407 @@
+// Package dnssrv is a minimal DNS client for looking up SRV records along
+// with their TTLs, which the standard library resolver does not expose.
+package dnssrv
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	typeSRV   = 33
+	classINET = 1
+
+	flagResponse  = 1 << 15
+	flagTruncated = 1 << 9
+	flagRecursion = 1 << 8
+
+	rcodeNameError = 3
+
+	// DefaultTimeout - How long a lookup waits for the DNS server by default.
+	DefaultTimeout = 2 * time.Second
+)
+
+// ErrNotFound - Error returned when the name has no SRV records.
+var ErrNotFound = errors.New("no srv records found")
+
+// Record - An SRV record.
+type Record struct {
+	Target   string        // Host name of the instance, without the trailing dot.
+	Port     uint16        // Port the instance listens on.
+	Priority uint16        // Priority of the instance, lower is preferred.
+	Weight   uint16        // Relative weight among instances of the same priority.
+	TTL      time.Duration // How long the record may be cached for.
+}
+
+// Client - Looks up SRV records from a DNS server.
+//
+// Relative names are tried with each search domain in turn, as the system
+// resolver does, so that names such as orders-master-staging.orders resolve
+// through the cluster search domains, e.g. svc.cluster.local. Names with a
+// trailing dot are only tried as they are.
+type Client struct {
+	Server  string        // Address of the DNS server, the first system nameserver if empty.
+	Timeout time.Duration // How long to wait for the server, DefaultTimeout if 0.
+	Search  []string      // Search domains, the system search domains if nil and Server is empty.
+	NDots   int           // Dots a name needs to be tried as it is before the search domains, the system setting or 1 if 0.
+}
+
+// resolvConf - Settings of the system resolver.
+type resolvConf struct {
+	server string
+	search []string
+	ndots  int
+}
+
+// LookupSRV - Look up the SRV records for a name, trying it with each search
+// domain until one has records.
+func (c *Client) LookupSRV(ctx context.Context, name string) ([]Record, error) {
+	conf, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, candidate := range candidates(name, conf.search, conf.ndots) {
+		records, err := lookup(ctx, conf.server, candidate)
+		if !errors.Is(err, ErrNotFound) {
+			return records, err
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// candidates - Get the fully qualified names to try for a name, in order.
+// Names with at least ndots dots are tried as they are before the search
+// domains, and names with fewer are tried after them.
+func candidates(name string, search []string, ndots int) []string {
+	if strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+
+	suffixed := make([]string, 0, len(search)+1)
+	for _, domain := range search {
+		suffixed = append(suffixed, name+"."+strings.Trim(domain, ".")+".")
+	}
+
+	if strings.Count(name, ".") >= ndots {
+		return append([]string{name + "."}, suffixed...)
+	}
+
+	return append(suffixed, name+".")
+}
+
+// lookup - Look up the SRV records for a fully qualified name.
+func lookup(ctx context.Context, server, name string) ([]Record, error) {
+	id := uint16(rand.Intn(1 << 16))
+	query, err := buildQuery(id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := exchange(ctx, "udp", server, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fall back to TCP if the answer did not fit in a datagram.
+	if len(msg) >= 4 && binary.BigEndian.Uint16(msg[2:])&flagTruncated != 0 {
+		if msg, err = exchange(ctx, "tcp", server, query); err != nil {
+			return nil, err
+		}
+	}
+
+	return parseResponse(id, msg)
+}
+
+// config - Get the DNS server to query and the search settings, from the
+// client or else from the system resolver.
+func (c *Client) config() (resolvConf, error) {
+	conf := resolvConf{server: c.Server, search: c.Search, ndots: c.NDots}
+
+	if conf.server == "" {
+		f, err := os.Open("/etc/resolv.conf")
+		if err != nil {
+			return conf, fmt.Errorf("cannot find a dns server: %w", err)
+		}
+		defer f.Close()
+
+		system, err := parseResolvConf(f)
+		if err != nil {
+			return conf, err
+		}
+
+		conf.server = system.server
+		if conf.search == nil {
+			conf.search = system.search
+		}
+		if conf.ndots == 0 {
+			conf.ndots = system.ndots
+		}
+	}
+
+	if conf.ndots == 0 {
+		conf.ndots = 1
+	}
+
+	return conf, nil
+}
+
+// parseResolvConf - Parse the first nameserver, the search domains and the
+// ndots option from a resolv.conf file.
+func parseResolvConf(r io.Reader) (resolvConf, error) {
+	conf := resolvConf{ndots: 1}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "nameserver":
+			if conf.server == "" {
+				conf.server = net.JoinHostPort(fields[1], "53")
+			}
+		case "domain", "search":
+			// The last of the domain and search lines wins.
+			conf.search = fields[1:]
+		case "options":
+			for _, option := range fields[1:] {
+				if value := strings.TrimPrefix(option, "ndots:"); value != option {
+					if ndots, err := strconv.Atoi(value); err == nil && ndots >= 0 {
+						conf.ndots = ndots
+					}
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return conf, fmt.Errorf("cannot read /etc/resolv.conf: %w", err)
+	}
+	if conf.server == "" {
+		return conf, errors.New("cannot find a dns server: no nameserver in /etc/resolv.conf")
+	}
+
+	return conf, nil
+}
+
+// exchange - Send a query to the server and read its response.
+func exchange(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, server)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach dns server: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, fmt.Errorf("cannot send dns query: %w", err)
+		}
+
+		msg := make([]byte, 4096)
+		n, err := conn.Read(msg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read dns response: %w", err)
+		}
+
+		return msg[:n], nil
+	}
+
+	// Messages over TCP are prefixed with their length.
+	framed := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(framed, uint16(len(query)))
+	copy(framed[2:], query)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, fmt.Errorf("cannot send dns query: %w", err)
+	}
+
+	length := make([]byte, 2)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return nil, fmt.Errorf("cannot read dns response: %w", err)
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return nil, fmt.Errorf("cannot read dns response: %w", err)
+	}
+
+	return msg, nil
+}
+
+// buildQuery - Build a recursive SRV query for a name.
+func buildQuery(id uint16, name string) ([]byte, error) {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], flagRecursion)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+
+	msg, err := appendName(msg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	question := make([]byte, 4)
+	binary.BigEndian.PutUint16(question[0:], typeSRV)
+	binary.BigEndian.PutUint16(question[2:], classINET)
+
+	return append(msg, question...), nil
+}
+
+// appendName - Append a name in DNS label format.
+func appendName(msg []byte, name string) ([]byte, error) {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid dns name %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+
+	return append(msg, 0), nil
+}
+
+// parseResponse - Parse the SRV records out of a response to a query.
+func parseResponse(id uint16, msg []byte) ([]Record, error) {
+	if len(msg) < 12 {
+		return nil, errors.New("malformed dns response: short header")
+	}
+
+	flags := binary.BigEndian.Uint16(msg[2:])
+	switch {
+	case binary.BigEndian.Uint16(msg[0:]) != id:
+		return nil, errors.New("malformed dns response: mismatched id")
+	case flags&flagResponse == 0:
+		return nil, errors.New("malformed dns response: not a response")
+	case flags&0xF == rcodeNameError:
+		return nil, ErrNotFound
+	case flags&0xF != 0:
+		return nil, fmt.Errorf("dns server failed with rcode %d", flags&0xF)
+	}
+
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	answers := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := 12
+	for i := 0; i < questions; i++ {
+		var err error
+		if _, off, err = readName(msg, off); err != nil {
+			return nil, err
+		}
+		off += 4
+	}
+
+	records := []Record{}
+	for i := 0; i < answers; i++ {
+		var err error
+		if _, off, err = readName(msg, off); err != nil {
+			return nil, err
+		}
+
+		if off+10 > len(msg) {
+			return nil, errors.New("malformed dns response: short answer")
+		}
+		rrType := binary.BigEndian.Uint16(msg[off:])
+		ttl := binary.BigEndian.Uint32(msg[off+4:])
+		length := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+
+		if off+length > len(msg) {
+			return nil, errors.New("malformed dns response: short answer data")
+		}
+
+		// Skip anything which is not an SRV record, e.g. CNAMEs.
+		if rrType != typeSRV {
+			off += length
+			continue
+		}
+
+		if length < 7 {
+			return nil, errors.New("malformed dns response: short srv record")
+		}
+		target, _, err := readName(msg, off+6)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, Record{
+			Priority: binary.BigEndian.Uint16(msg[off:]),
+			Weight:   binary.BigEndian.Uint16(msg[off+2:]),
+			Port:     binary.BigEndian.Uint16(msg[off+4:]),
+			Target:   target,
+			TTL:      time.Duration(ttl) * time.Second,
+		})
+		off += length
+	}
+
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return records, nil
+}
+
+// readName - Read a possibly compressed name, returning it along with the
+// offset just past it.
+func readName(msg []byte, off int) (string, int, error) {
+	labels := []string{}
+	end := -1
+
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errors.New("malformed dns response: short name")
+		}
+
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, "."), end, nil
+
+		// A pointer to a name elsewhere in the message.
+		case length&0xC0 == 0xC0:
+			if off+1 >= len(msg) {
+				return "", 0, errors.New("malformed dns response: short name pointer")
+			}
+			if jumps++; jumps > 10 {
+				return "", 0, errors.New("malformed dns response: too many name pointers")
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+
+		default:
+			if off+1+length > len(msg) {
+				return "", 0, errors.New("malformed dns response: short label")
+			}
+			labels = append(labels, string(msg[off+1:off+1+length]))
+			off += 1 + length
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package dnssrv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	conf, err := parseResolvConf(strings.NewReader(`# Generated by the cluster
+nameserver 10.96.0.10
+nameserver 10.96.0.11
+search default.svc.cluster.local svc.cluster.local cluster.local
+options ndots:5 timeout:1
+`))
+	if err != nil {
+		t.Fatalf("TestParseResolvConf: %s", err)
+	}
+
+	expected := resolvConf{
+		server: "10.96.0.10:53",
+		search: []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		ndots:  5,
+	}
+	if !reflect.DeepEqual(conf, expected) {
+		t.Errorf("TestParseResolvConf: expected %v got %v", expected, conf)
+	}
+
+	if _, err := parseResolvConf(strings.NewReader("search cluster.local\n")); err == nil {
+		t.Errorf("TestParseResolvConf: expected an error without a nameserver")
+	}
+}
+
+func TestReadName(t *testing.T) {
+	// "orders.services" at offset 0, then "orders-0" followed by a pointer to "services".
+	msg := []byte("\x06orders\x08services\x00\x08orders-0\xC0\x07")
+
+	tt := []struct {
+		name         string
+		off          int
+		expectedName string
+		expectedEnd  int
+		expectedErr  bool
+	}{
+		{
+			name:         "Uncompressed",
+			off:          0,
+			expectedName: "orders.services",
+			expectedEnd:  17,
+		},
+		{
+			name:         "Compressed",
+			off:          17,
+			expectedName: "orders-0.services",
+			expectedEnd:  len(msg),
+		},
+		{
+			name:        "Truncated",
+			off:         len(msg) - 1,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			name, end, err := readName(msg, tc.off)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("TestReadName: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+
+			if !tc.expectedErr && (name != tc.expectedName || end != tc.expectedEnd) {
+				t.Errorf("TestReadName: %s: expected %s at %d got %s at %d", tc.name, tc.expectedName, tc.expectedEnd, name, end)
+			}
+		})
+	}
+
+	loop := []byte("\xC0\x00")
+	if _, _, err := readName(loop, 0); err == nil {
+		t.Errorf("TestReadName: expected an error for a pointer loop")
+	}
+}
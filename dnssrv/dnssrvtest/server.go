@@ -0,0 +1,179 @@
+// Package dnssrvtest provides an in-process DNS server answering SRV
+// queries, for testing code which looks records up with dnssrv.
+package dnssrvtest
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/LUSHDigital/microservice-transport-golang/dnssrv"
+)
+
+const (
+	typeSRV   = 33
+	classINET = 1
+
+	flagResponse  = 1 << 15
+	flagRecursion = 1 << 8
+	flagAvailable = 1 << 7
+
+	rcodeNameError = 3
+)
+
+// Server - In-process DNS server answering SRV queries over UDP from a
+// fixed set of records, for use in tests.
+type Server struct {
+	Addr string // Address the server listens on.
+
+	mu      sync.Mutex
+	records map[string][]dnssrv.Record
+	queries int
+	conn    net.PacketConn
+}
+
+// NewServer - Start a server on a local port, answering with the provided
+// records keyed by name.
+func NewServer(records map[string][]dnssrv.Record) (*Server, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Addr:    conn.LocalAddr().String(),
+		records: make(map[string][]dnssrv.Record),
+		conn:    conn,
+	}
+	for name, rs := range records {
+		s.SetRecords(name, rs)
+	}
+
+	go s.serve()
+
+	return s, nil
+}
+
+// SetRecords - Replace the records served for a name.
+func (s *Server) SetRecords(name string, records []dnssrv.Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[strings.TrimSuffix(name, ".")] = records
+}
+
+// Queries - Get the number of queries the server has answered.
+func (s *Server) Queries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.queries
+}
+
+// Close - Stop the server.
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+// serve - Answer queries until the server is closed.
+func (s *Server) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		if msg := s.answer(buf[:n]); msg != nil {
+			s.conn.WriteTo(msg, addr)
+		}
+	}
+}
+
+// answer - Build the response to a query.
+func (s *Server) answer(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	name, end, err := readQuestionName(query, 12)
+	if err != nil || end+4 > len(query) {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.queries++
+	records := s.records[name]
+	s.mu.Unlock()
+
+	msg := make([]byte, 12, 512)
+	copy(msg, query[:2])
+	flags := uint16(flagResponse | flagRecursion | flagAvailable)
+	if len(records) == 0 {
+		flags |= rcodeNameError
+	}
+	binary.BigEndian.PutUint16(msg[2:], flags)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(records)))
+
+	// Echo the question.
+	msg = append(msg, query[12:end+4]...)
+
+	for _, record := range records {
+		rdata := make([]byte, 6)
+		binary.BigEndian.PutUint16(rdata[0:], record.Priority)
+		binary.BigEndian.PutUint16(rdata[2:], record.Weight)
+		binary.BigEndian.PutUint16(rdata[4:], record.Port)
+		rdata, _ = appendName(rdata, record.Target)
+
+		// Point back at the name in the question.
+		rr := make([]byte, 12)
+		binary.BigEndian.PutUint16(rr[0:], 0xC00C)
+		binary.BigEndian.PutUint16(rr[2:], typeSRV)
+		binary.BigEndian.PutUint16(rr[4:], classINET)
+		binary.BigEndian.PutUint32(rr[6:], uint32(record.TTL.Seconds()))
+		binary.BigEndian.PutUint16(rr[10:], uint16(len(rdata)))
+
+		msg = append(msg, rr...)
+		msg = append(msg, rdata...)
+	}
+
+	return msg
+}
+
+// readQuestionName - Read the uncompressed name of a question, returning it
+// along with the offset just past it.
+func readQuestionName(msg []byte, off int) (string, int, error) {
+	labels := []string{}
+	for {
+		if off >= len(msg) {
+			return "", 0, errors.New("short name")
+		}
+
+		length := int(msg[off])
+		if length == 0 {
+			return strings.Join(labels, "."), off + 1, nil
+		}
+		if length&0xC0 != 0 || off+1+length > len(msg) {
+			return "", 0, errors.New("malformed name")
+		}
+
+		labels = append(labels, string(msg[off+1:off+1+length]))
+		off += 1 + length
+	}
+}
+
+// appendName - Append a name in DNS label format.
+func appendName(msg []byte, name string) ([]byte, error) {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, errors.New("invalid dns name " + name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+
+	return append(msg, 0), nil
+}
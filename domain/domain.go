@@ -1,8 +1,88 @@
 package domain
 
-// BuildServiceDNSName - Build the full DNS name for a service.
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxLabelLength - Longest a single label of a DNS name may be.
+const MaxLabelLength = 63
+
+// labelHashLength - Length of the hash suffix given to truncated labels.
+const labelHashLength = 8
+
+// ErrInvalidName - Error returned for names which cannot be made DNS-safe.
+var ErrInvalidName = errors.New("invalid name")
+
+// BuildServiceDNSName - Build the full DNS name for a service. Each part is
+// normalised with NormaliseName, and labels longer than 63 characters are
+// truncated with TruncateLabel.
 func BuildServiceDNSName(service, branch, environment, serviceNamespace string) string {
-	return service + "-" + branch + "-" + environment + "." + serviceNamespace
+	label := NormaliseName(service) + "-" + NormaliseName(branch) + "-" + NormaliseName(environment)
+
+	return TruncateLabel(label) + "." + TruncateLabel(NormaliseName(serviceNamespace))
+}
+
+// ValidateServiceDNSName - Check every part of the DNS name for a service
+// has something left once normalised, returning an error wrapping
+// ErrInvalidName for the first part which does not.
+func ValidateServiceDNSName(service, branch, environment, serviceNamespace string) error {
+	parts := []struct {
+		label string
+		value string
+	}{
+		{"service", service},
+		{"branch", branch},
+		{"environment", environment},
+		{"service namespace", serviceNamespace},
+	}
+
+	for _, part := range parts {
+		if part.value == "" {
+			return fmt.Errorf("%w: missing %s", ErrInvalidName, part.label)
+		}
+		if NormaliseName(part.value) == "" {
+			return fmt.Errorf("%w: %s %q has no letters or digits", ErrInvalidName, part.label, part.value)
+		}
+	}
+
+	return nil
+}
+
+// NormaliseName - Make a name DNS-safe the way deployments are named, e.g.
+// feature/ABC-12_new becomes feature-abc-12-new. The name is lowercased,
+// anything but letters, digits and dashes becomes a dash, and leading and
+// trailing dashes are dropped.
+func NormaliseName(name string) string {
+	normalised := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, name)
+
+	return strings.Trim(normalised, "-")
+}
+
+// TruncateLabel - Shorten a DNS label longer than 63 characters, keeping
+// it unique by replacing its end with a hash of the whole label.
+func TruncateLabel(label string) string {
+	if len(label) <= MaxLabelLength {
+		return label
+	}
+
+	sum := sha256.Sum256([]byte(label))
+	hash := hex.EncodeToString(sum[:])[:labelHashLength]
+	prefix := strings.TrimRight(label[:MaxLabelLength-labelHashLength-1], "-")
+
+	return prefix + "-" + hash
 }
 
 // BuildCloudServiceUrl - Build the full URL for a cloud service.
@@ -1,7 +1,9 @@
 package domain
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -28,7 +30,23 @@ func TestBuildServiceDNSName(t *testing.T) {
 			branch:           "(!()*)(*!KJ",
 			environment:      "sljsjlfjdkgj",
 			serviceNamespace: ")ID`hdfy7d7f",
-			expectedDnsName:  "21323kl1j3913issvxc9vx0-(!()*)(*!KJ-sljsjlfjdkgj.)ID`hdfy7d7f",
+			expectedDnsName:  "21323kl1j3913issvxc9vx0-kj-sljsjlfjdkgj.id-hdfy7d7f",
+		},
+		{
+			name:             "Branch with slashes and underscores",
+			service:          "test",
+			branch:           "feature/ABC-12_new",
+			environment:      "staging",
+			serviceNamespace: "test",
+			expectedDnsName:  "test-feature-abc-12-new-staging.test",
+		},
+		{
+			name:             "Long branch",
+			service:          "orders",
+			branch:           "feature/" + strings.Repeat("a", 60),
+			environment:      "staging",
+			serviceNamespace: "orders",
+			expectedDnsName:  "orders-feature-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-90f1250e.orders",
 		},
 	}
 
@@ -49,6 +67,96 @@ func ExampleBuildServiceDNSName() {
 	// Output: myservice-master-staging.services
 }
 
+func TestValidateServiceDNSName(t *testing.T) {
+	tt := []struct {
+		name             string
+		service          string
+		branch           string
+		environment      string
+		serviceNamespace string
+		expectedErr      string
+	}{
+		{
+			name:             "Valid",
+			service:          "test",
+			branch:           "feature/ABC-12_new",
+			environment:      "staging",
+			serviceNamespace: "test",
+		},
+		{
+			name:             "Missing branch",
+			service:          "test",
+			environment:      "staging",
+			serviceNamespace: "test",
+			expectedErr:      "invalid name: missing branch",
+		},
+		{
+			name:             "Nothing DNS-safe",
+			service:          "test",
+			branch:           "master",
+			environment:      "_/_",
+			serviceNamespace: "test",
+			expectedErr:      `invalid name: environment "_/_" has no letters or digits`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateServiceDNSName(tc.service, tc.branch, tc.environment, tc.serviceNamespace)
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Errorf("TestValidateServiceDNSName: %s: expected no error got %v", tc.name, err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != tc.expectedErr {
+				t.Errorf("TestValidateServiceDNSName: %s: expected %v got %v", tc.name, tc.expectedErr, err)
+			}
+			if !errors.Is(err, ErrInvalidName) {
+				t.Errorf("TestValidateServiceDNSName: %s: expected ErrInvalidName got %v", tc.name, err)
+			}
+		})
+	}
+}
+
+func TestTruncateLabel(t *testing.T) {
+	tt := []struct {
+		name          string
+		label         string
+		expectedLabel string
+	}{
+		{
+			name:          "Short label",
+			label:         strings.Repeat("a", 63),
+			expectedLabel: strings.Repeat("a", 63),
+		},
+		{
+			name:          "Long label",
+			label:         strings.Repeat("a", 64),
+			expectedLabel: strings.Repeat("a", 54) + "-ffe054fe",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actualLabel := TruncateLabel(tc.label)
+			if actualLabel != tc.expectedLabel {
+				t.Errorf("TestTruncateLabel: %s: expected %v got %v", tc.name, tc.expectedLabel, actualLabel)
+			}
+			if len(actualLabel) > MaxLabelLength {
+				t.Errorf("TestTruncateLabel: %s: expected at most %d characters got %d", tc.name, MaxLabelLength, len(actualLabel))
+			}
+		})
+	}
+}
+
+func ExampleNormaliseName() {
+	fmt.Println(NormaliseName("feature/ABC-12_new"))
+
+	// Output: feature-abc-12-new
+}
+
 func TestBuildCloudServiceUrl(t *testing.T) {
 	tt := []struct {
 		name                    string
@@ -1,5 +1,14 @@
 package errors
 
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/LUSHDigital/microservice-core-golang/response"
+)
+
 // LoginUnauthorisedError - Error to throw when a login was unauthorised.
 type LoginUnauthorisedError struct{}
 
@@ -15,3 +24,140 @@ type ConsumerHasNoTokensError struct{}
 func (e ConsumerHasNoTokensError) Error() string {
 	return "consumer has no tokens"
 }
+
+// CircuitOpenError - Error to throw when the circuit breaker for a service is
+// open and calls to it are being rejected.
+type CircuitOpenError struct {
+	Service string // Identity of the service the circuit is open for.
+}
+
+// Error - Error string when the circuit for a service is open.
+func (e CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for service %s", e.Service)
+}
+
+// MaxErrorBodySize - Most bytes of a response body kept by a ServiceError.
+const MaxErrorBodySize = 4096
+
+// ServiceError - Error to throw when a service responds with an error.
+//
+// A ServiceError matches the StatusClass of its status code with errors.Is, so
+// callers can check for e.g. ErrNotFound without inspecting the status code.
+type ServiceError struct {
+	Service    string             // Name of the service.
+	URL        string             // Resolved URL of the request.
+	Method     string             // HTTP method of the request.
+	StatusCode int                // HTTP status code of the response.
+	Response   *response.Response // Decoded response envelope, if the body held one.
+	Body       []byte             // Raw response body, truncated to MaxErrorBodySize.
+}
+
+// NewServiceError - Build a ServiceError from a service response and its body.
+func NewServiceError(service string, resp *http.Response, body []byte) *ServiceError {
+	e := &ServiceError{
+		Service:    service,
+		StatusCode: resp.StatusCode,
+	}
+
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = resp.Request.URL.String()
+	}
+
+	serviceResponse := &response.Response{}
+	if err := json.Unmarshal(body, serviceResponse); err == nil {
+		e.Response = serviceResponse
+	}
+
+	if len(body) > MaxErrorBodySize {
+		body = body[:MaxErrorBodySize]
+	}
+	e.Body = append([]byte(nil), body...)
+
+	return e
+}
+
+// Error - Error string for a service error.
+func (e *ServiceError) Error() string {
+	parts := []string{}
+	if e.Service != "" {
+		parts = append(parts, e.Service)
+	}
+	if e.Method != "" || e.URL != "" {
+		parts = append(parts, strings.TrimSpace(e.Method+" "+e.URL))
+	}
+	parts = append(parts, fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)))
+	if e.Response != nil && e.Response.Message != "" {
+		parts = append(parts, e.Response.Message)
+	}
+
+	return strings.Join(parts, ": ")
+}
+
+// Is - Check whether the error falls into a status class.
+func (e *ServiceError) Is(target error) bool {
+	class, ok := target.(StatusClass)
+	return ok && class.matches(e.StatusCode)
+}
+
+// As - Set a StatusClass target to the class of the error.
+func (e *ServiceError) As(target interface{}) bool {
+	class, ok := target.(*StatusClass)
+	if !ok {
+		return false
+	}
+
+	for _, candidate := range statusClasses {
+		if candidate.matches(e.StatusCode) {
+			*class = candidate
+			return true
+		}
+	}
+
+	return false
+}
+
+// StatusClass - Class of error status returned by a service.
+type StatusClass int
+
+// Status classes matched by ServiceError.
+const (
+	ErrBadRequest          StatusClass = http.StatusBadRequest
+	ErrUnauthorised        StatusClass = http.StatusUnauthorized
+	ErrForbidden           StatusClass = http.StatusForbidden
+	ErrNotFound            StatusClass = http.StatusNotFound
+	ErrConflict            StatusClass = http.StatusConflict
+	ErrUnprocessableEntity StatusClass = http.StatusUnprocessableEntity
+	ErrTooManyRequests     StatusClass = http.StatusTooManyRequests
+	ErrServerError         StatusClass = http.StatusInternalServerError // Any 5xx status.
+)
+
+// statusClasses - Every status class, checked in order by ServiceError.As.
+var statusClasses = []StatusClass{
+	ErrBadRequest,
+	ErrUnauthorised,
+	ErrForbidden,
+	ErrNotFound,
+	ErrConflict,
+	ErrUnprocessableEntity,
+	ErrTooManyRequests,
+	ErrServerError,
+}
+
+// Error - Error string for the status class.
+func (c StatusClass) Error() string {
+	if c == ErrServerError {
+		return "server error"
+	}
+
+	return strings.ToLower(http.StatusText(int(c)))
+}
+
+// matches - Check whether a status code falls into the class.
+func (c StatusClass) matches(code int) bool {
+	if c == ErrServerError {
+		return code >= 500 && code < 600
+	}
+
+	return code == int(c)
+}
@@ -0,0 +1,111 @@
+package errors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestServiceError_Is(t *testing.T) {
+	classes := []StatusClass{
+		ErrBadRequest,
+		ErrUnauthorised,
+		ErrForbidden,
+		ErrNotFound,
+		ErrConflict,
+		ErrUnprocessableEntity,
+		ErrTooManyRequests,
+		ErrServerError,
+	}
+
+	tt := []struct {
+		name          string
+		statusCode    int
+		expectedClass StatusClass
+	}{
+		{name: "400", statusCode: http.StatusBadRequest, expectedClass: ErrBadRequest},
+		{name: "401", statusCode: http.StatusUnauthorized, expectedClass: ErrUnauthorised},
+		{name: "403", statusCode: http.StatusForbidden, expectedClass: ErrForbidden},
+		{name: "404", statusCode: http.StatusNotFound, expectedClass: ErrNotFound},
+		{name: "409", statusCode: http.StatusConflict, expectedClass: ErrConflict},
+		{name: "422", statusCode: http.StatusUnprocessableEntity, expectedClass: ErrUnprocessableEntity},
+		{name: "429", statusCode: http.StatusTooManyRequests, expectedClass: ErrTooManyRequests},
+		{name: "500", statusCode: http.StatusInternalServerError, expectedClass: ErrServerError},
+		{name: "503", statusCode: http.StatusServiceUnavailable, expectedClass: ErrServerError},
+		{name: "418", statusCode: http.StatusTeapot},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", &ServiceError{Service: "myservice", StatusCode: tc.statusCode})
+
+			for _, class := range classes {
+				if matched := errors.Is(err, class); matched != (class == tc.expectedClass) {
+					t.Errorf("TestServiceError_Is: %s: expected match with %v to be %v got %v", tc.name, class, class == tc.expectedClass, matched)
+				}
+			}
+
+			var class StatusClass
+			if matched := errors.As(err, &class); matched != (tc.expectedClass != 0) || class != tc.expectedClass {
+				t.Errorf("TestServiceError_Is: %s: expected class %v got %v", tc.name, tc.expectedClass, class)
+			}
+
+			var serviceErr *ServiceError
+			if !errors.As(err, &serviceErr) || serviceErr.StatusCode != tc.statusCode {
+				t.Errorf("TestServiceError_Is: %s: expected a service error with status %v", tc.name, tc.statusCode)
+			}
+		})
+	}
+}
+
+func TestNewServiceError(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://myservice-master-staging.myservice/things/1", nil)
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Request:    req,
+		Body:       ioutil.NopCloser(&bytes.Buffer{}),
+	}
+
+	tt := []struct {
+		name            string
+		body            []byte
+		expectedMessage string
+		expectedBodyLen int
+		expectedError   string
+	}{
+		{
+			name:            "Envelope",
+			body:            []byte(`{"status":"fail","code":404,"message":"thing not found"}`),
+			expectedMessage: "thing not found",
+			expectedBodyLen: 56,
+			expectedError:   "myservice: GET http://myservice-master-staging.myservice/things/1: 404 Not Found: thing not found",
+		},
+		{
+			name:            "Large raw body",
+			body:            bytes.Repeat([]byte("x"), MaxErrorBodySize*2),
+			expectedBodyLen: MaxErrorBodySize,
+			expectedError:   "myservice: GET http://myservice-master-staging.myservice/things/1: 404 Not Found",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewServiceError("myservice", resp, tc.body)
+
+			if tc.expectedMessage != "" && (err.Response == nil || err.Response.Message != tc.expectedMessage) {
+				t.Errorf("TestNewServiceError: %s: expected message %v got %v", tc.name, tc.expectedMessage, err.Response)
+			}
+
+			if len(err.Body) != tc.expectedBodyLen {
+				t.Errorf("TestNewServiceError: %s: expected body length %v got %v", tc.name, tc.expectedBodyLen, len(err.Body))
+			}
+
+			if err.Error() != tc.expectedError {
+				t.Errorf("TestNewServiceError: %s: expected %v got %v", tc.name, tc.expectedError, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/config"
+)
+
+const (
+	// DefaultBranchCacheTTL - How long a branch fallback resolver remembers
+	// the branch it found by default.
+	DefaultBranchCacheTTL = 30 * time.Second
+
+	// DefaultBranchDialTimeout - How long a branch fallback resolver waits to
+	// connect to a branch deployment by default.
+	DefaultBranchDialTimeout = time.Second
+)
+
+// BranchFallbackResolver - Resolves services to the deployment of their own
+// branch, falling back along a chain of branches when it does not exist, e.g.
+// feature-x, then develop, then master.
+//
+// A branch deployment is taken not to exist when its host does not resolve or
+// refuses connections on the port it is called on, which is that of the
+// protocol of the request unless the base URL or Port says otherwise. The
+// branch found for each service is cached.
+// A BranchFallbackResolver is safe for concurrent use.
+type BranchFallbackResolver struct {
+	Resolver    Resolver      // Resolver for each branch, DefaultResolver if nil.
+	Fallbacks   []string      // Branches to fall back to, in order.
+	CacheTTL    time.Duration // How long to remember the branch found, DefaultBranchCacheTTL if 0.
+	DialTimeout time.Duration // How long to wait to connect to a branch, DefaultBranchDialTimeout if 0.
+	Port        int           // Port to connect to for base URLs without one, that of the protocol called with if 0.
+
+	mu    sync.Mutex
+	cache map[Identity]branchCacheEntry
+	dial  func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// branchCacheEntry - Base URLs of the branch found for a service.
+type branchCacheEntry struct {
+	branch    string
+	urls      []*url.URL
+	expiresAt time.Time
+}
+
+// NewBranchFallbackResolver - Prepare a new branch fallback resolver, falling
+// back to the provided branches in order.
+func NewBranchFallbackResolver(resolver Resolver, fallbacks ...string) *BranchFallbackResolver {
+	return &BranchFallbackResolver{
+		Resolver:  resolver,
+		Fallbacks: fallbacks,
+	}
+}
+
+// Resolve - Get the base URLs of the first branch in the chain which has a
+// deployment of the service.
+func (r *BranchFallbackResolver) Resolve(ctx context.Context, identity Identity) ([]*url.URL, error) {
+	if entry, ok := r.cached(identity); ok {
+		return entry.urls, nil
+	}
+
+	tried := []string{}
+	var lastErr error
+	for _, branch := range r.chain(identity.Branch) {
+		tried = append(tried, branch)
+
+		branchIdentity := identity
+		branchIdentity.Branch = branch
+
+		urls, err := r.getResolver().Resolve(ctx, branchIdentity)
+		if err == nil && len(urls) == 0 {
+			err = errors.New("no instances found")
+		}
+		if err == nil {
+			err = r.probe(ctx, urls[0], ProtocolFromContext(ctx))
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		r.store(identity, branch, urls)
+
+		return urls, nil
+	}
+
+	return nil, fmt.Errorf("no deployment found on branches %s: %w", strings.Join(tried, ", "), lastErr)
+}
+
+// Branch - Get the branch a service was last resolved to, if it is cached.
+func (r *BranchFallbackResolver) Branch(identity Identity) (string, bool) {
+	entry, ok := r.cached(identity)
+
+	return entry.branch, ok
+}
+
+// chain - Get the branches to try, starting with the branch of the service.
+func (r *BranchFallbackResolver) chain(branch string) []string {
+	chain := []string{branch}
+	for _, fallback := range r.Fallbacks {
+		if fallback != branch {
+			chain = append(chain, fallback)
+		}
+	}
+
+	return chain
+}
+
+// probe - Check whether the instance at a base URL exists, returning an error
+// if its host does not resolve or refuses connections. Any other outcome is
+// left for the call itself to deal with.
+func (r *BranchFallbackResolver) probe(ctx context.Context, baseUrl *url.URL, protocol string) error {
+	timeout := r.DialTimeout
+	if timeout == 0 {
+		timeout = DefaultBranchDialTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr := baseUrl.Host
+	if baseUrl.Port() == "" {
+		addr = net.JoinHostPort(baseUrl.Hostname(), r.port(baseUrl, protocol))
+	}
+
+	dial := r.dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	conn, err := dial(ctx, "tcp", addr)
+	if err == nil {
+		conn.Close()
+		return nil
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+
+	return nil
+}
+
+// port - Get the port to connect to for a base URL without one, from the
+// scheme of the base URL, or else the protocol the service is called with.
+func (r *BranchFallbackResolver) port(baseUrl *url.URL, protocol string) string {
+	if r.Port != 0 {
+		return strconv.Itoa(r.Port)
+	}
+
+	if baseUrl.Scheme != "" {
+		protocol = baseUrl.Scheme
+	}
+	if protocol == config.ProtocolHTTPS {
+		return "443"
+	}
+
+	return "80"
+}
+
+// cached - Get the branch found for a service, if it is still fresh.
+func (r *BranchFallbackResolver) cached(identity Identity) (branchCacheEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.cache[identity]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return branchCacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+// store - Cache the base URLs of the branch found for a service.
+func (r *BranchFallbackResolver) store(identity Identity, branch string, urls []*url.URL) {
+	ttl := r.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultBranchCacheTTL
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cache == nil {
+		r.cache = make(map[Identity]branchCacheEntry)
+	}
+	r.cache[identity] = branchCacheEntry{
+		branch:    branch,
+		urls:      urls,
+		expiresAt: time.Now().Add(ttl),
+	}
+}
+
+// getResolver - Get the resolver used for each branch.
+func (r *BranchFallbackResolver) getResolver() Resolver {
+	if r.Resolver != nil {
+		return r.Resolver
+	}
+
+	return DefaultResolver
+}
@@ -0,0 +1,135 @@
+package microservicetransport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/config"
+)
+
+// countingResolver - Counts the resolutions made through a resolver.
+type countingResolver struct {
+	Resolver
+	count int32
+}
+
+func (r *countingResolver) Resolve(ctx context.Context, identity Identity) ([]*url.URL, error) {
+	atomic.AddInt32(&r.count, 1)
+	return r.Resolver.Resolve(ctx, identity)
+}
+
+func TestBranchFallbackResolver_Resolve(t *testing.T) {
+	// Start a HTTP server to act as the master deployment.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	// Find a port nothing listens on, to act as a deployment which refuses
+	// connections.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("TestBranchFallbackResolver_Resolve: %s", err)
+	}
+	refused := "http://" + l.Addr().String()
+	l.Close()
+
+	resolver := &countingResolver{Resolver: &StaticResolver{Entries: []StaticEntry{
+		{Name: "orders", Branch: "feature-x", URLs: []string{refused}},
+		{Name: "orders", Branch: "develop", URLs: []string{"http://orders-develop.invalid"}},
+		{Name: "orders", Branch: "master", URLs: []string{ts.URL}},
+	}}}
+
+	fallback := NewBranchFallbackResolver(resolver, "develop", "master")
+	identity := Identity{Name: "orders", Namespace: "services", Branch: "feature-x", Environment: "staging"}
+
+	urls, err := fallback.Resolve(context.Background(), identity)
+	if err != nil {
+		t.Fatalf("TestBranchFallbackResolver_Resolve: %s", err)
+	}
+	if len(urls) != 1 || urls[0].String() != ts.URL {
+		t.Errorf("TestBranchFallbackResolver_Resolve: expected %v got %v", ts.URL, urls)
+	}
+
+	if branch, ok := fallback.Branch(identity); !ok || branch != "master" {
+		t.Errorf("TestBranchFallbackResolver_Resolve: expected branch master got %v", branch)
+	}
+
+	// The branch found is cached.
+	if _, err := fallback.Resolve(context.Background(), identity); err != nil {
+		t.Fatalf("TestBranchFallbackResolver_Resolve: %s", err)
+	}
+	if resolver.count != 3 {
+		t.Errorf("TestBranchFallbackResolver_Resolve: expected 3 resolutions got %d", resolver.count)
+	}
+
+	// Without a deployment on any branch, every branch is reported.
+	identity.Name = "payments"
+	_, err = fallback.Resolve(context.Background(), identity)
+	if err == nil || !strings.Contains(err.Error(), "no deployment found on branches feature-x, develop, master") {
+		t.Errorf("TestBranchFallbackResolver_Resolve: expected every branch to be tried got %v", err)
+	}
+}
+
+func TestBranchFallbackResolver_Resolve_timeout(t *testing.T) {
+	fallback := NewBranchFallbackResolver(&StaticResolver{Entries: []StaticEntry{
+		{Name: "orders", URLs: []string{"http://orders.local"}},
+	}}, "master")
+
+	// A slow deployment still exists, so it is used rather than falling back.
+	fallback.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr != "orders.local:80" {
+			t.Errorf("TestBranchFallbackResolver_Resolve_timeout: expected orders.local:80 got %v", addr)
+		}
+
+		<-ctx.Done()
+		return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+	}
+	fallback.DialTimeout = 10 * time.Millisecond
+
+	identity := Identity{Name: "orders", Branch: "feature-x"}
+	if _, err := fallback.Resolve(context.Background(), identity); err != nil {
+		t.Fatalf("TestBranchFallbackResolver_Resolve_timeout: %s", err)
+	}
+
+	if branch, _ := fallback.Branch(identity); branch != "feature-x" {
+		t.Errorf("TestBranchFallbackResolver_Resolve_timeout: expected branch feature-x got %v", branch)
+	}
+}
+
+func TestService_Dial_branchFallbackHTTPS(t *testing.T) {
+	fallback := NewBranchFallbackResolver(DNSNameResolver{}, "master")
+
+	// The feature branch deployment only listens for https.
+	var dialled []string
+	fallback.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialled = append(dialled, addr)
+		if addr != "orders-feature-x-staging.orders:443" {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+		}
+
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	service := NewService(DefaultHttpClient(), "feature-x", "staging", "services", "orders")
+	service.Protocol = config.ProtocolHTTPS
+	service.Resolver = fallback
+
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+		t.Fatalf("TestService_Dial_branchFallbackHTTPS: %s", err)
+	}
+
+	expectedUrl := "https://orders-feature-x-staging.orders/things"
+	if service.CurrentRequest.URL.String() != expectedUrl {
+		t.Errorf("TestService_Dial_branchFallbackHTTPS: expected %v got %v (dialled %v)", expectedUrl, service.CurrentRequest.URL.String(), dialled)
+	}
+}
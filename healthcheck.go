@@ -0,0 +1,224 @@
+package microservicetransport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHealthResource - Resource health checks call by default.
+	DefaultHealthResource = "health"
+
+	// DefaultHealthInterval - How often health checks run by default.
+	DefaultHealthInterval = 10 * time.Second
+
+	// DefaultHealthTimeout - How long a health check waits by default.
+	DefaultHealthTimeout = 2 * time.Second
+)
+
+// HealthChecker - Periodically calls a health resource on each registered
+// service, and reports whether they are all healthy.
+//
+// The checker is an http.Handler serving its report, so it can back a
+// readiness probe:
+//
+//	checker := NewHealthChecker()
+//	checker.Register(orders)
+//	go checker.Run(ctx)
+//	http.Handle("/ready", checker)
+type HealthChecker struct {
+	Resource string        // Resource to call on each service, DefaultHealthResource if empty.
+	Interval time.Duration // How often to check, DefaultHealthInterval if 0.
+	Timeout  time.Duration // How long each check waits, DefaultHealthTimeout if 0.
+
+	mu       sync.Mutex
+	services []Dialer
+	results  map[Dialer]HealthResult
+}
+
+// HealthReport - Aggregated health of the services registered with a checker.
+type HealthReport struct {
+	Healthy  bool           `json:"healthy"`  // Whether every service is healthy.
+	Services []HealthResult `json:"services"` // Health of each service, in order of registration.
+}
+
+// HealthResult - Outcome of the latest health check of a service.
+type HealthResult struct {
+	Name      string        `json:"name"`             // Name of the service.
+	Healthy   bool          `json:"healthy"`          // Whether the service responded with a 2xx status.
+	Status    int           `json:"status,omitempty"` // Status the service responded with.
+	Latency   time.Duration `json:"-"`                // How long the service took to respond.
+	Error     string        `json:"error,omitempty"`  // Why the check failed, if it did.
+	CheckedAt time.Time     `json:"checked_at"`       // When the check was made.
+}
+
+// MarshalJSON - Encode the result, with its latency in milliseconds.
+func (r HealthResult) MarshalJSON() ([]byte, error) {
+	type result HealthResult
+	return json.Marshal(struct {
+		result
+		LatencyMs float64 `json:"latency_ms"`
+	}{result(r), float64(r.Latency) / float64(time.Millisecond)})
+}
+
+// NewHealthChecker - Prepare a new health checker with the default resource,
+// interval and timeout.
+func NewHealthChecker() *HealthChecker {
+	return &HealthChecker{
+		Resource: DefaultHealthResource,
+		Interval: DefaultHealthInterval,
+		Timeout:  DefaultHealthTimeout,
+		results:  make(map[Dialer]HealthResult),
+	}
+}
+
+// Register - Add a service to check. Services are unhealthy until they have
+// been checked.
+func (h *HealthChecker) Register(service Dialer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.services = append(h.services, service)
+}
+
+// Run - Check every service straight away and then on every interval, until
+// the context is done.
+func (h *HealthChecker) Run(ctx context.Context) {
+	interval := h.Interval
+	if interval == 0 {
+		interval = DefaultHealthInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		h.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check - Check every service once, concurrently, and get the resulting report.
+func (h *HealthChecker) Check(ctx context.Context) HealthReport {
+	h.mu.Lock()
+	services := append([]Dialer(nil), h.services...)
+	h.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, service := range services {
+		wg.Add(1)
+		go func(service Dialer) {
+			defer wg.Done()
+
+			result := h.check(ctx, service)
+
+			// A check cut short by the caller says nothing about the service.
+			if ctx.Err() != nil {
+				return
+			}
+
+			h.mu.Lock()
+			if h.results == nil {
+				h.results = make(map[Dialer]HealthResult)
+			}
+			h.results[service] = result
+			h.mu.Unlock()
+		}(service)
+	}
+	wg.Wait()
+
+	return h.Report()
+}
+
+// Report - Get the report from the latest checks.
+func (h *HealthChecker) Report() HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	report := HealthReport{Healthy: true, Services: []HealthResult{}}
+	for _, service := range h.services {
+		result, ok := h.results[service]
+		if !ok {
+			result = HealthResult{Name: service.GetName(), Error: "not checked yet"}
+		}
+
+		report.Healthy = report.Healthy && result.Healthy
+		report.Services = append(report.Services, result)
+	}
+
+	return report
+}
+
+// ServeHTTP - Serve the report from the latest checks as JSON, with a 200
+// status if every service is healthy and a 503 status otherwise.
+func (h *HealthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.Report()
+
+	status := http.StatusOK
+	if !report.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// check - Make a single attempt at calling the health resource of a service.
+func (h *HealthChecker) check(ctx context.Context, service Dialer) HealthResult {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultHealthTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resource := h.Resource
+	if resource == "" {
+		resource = DefaultHealthResource
+	}
+
+	result := HealthResult{
+		Name:      service.GetName(),
+		CheckedAt: time.Now(),
+	}
+
+	call, err := service.Prepare(ctx, &Request{Method: http.MethodGet, Resource: resource})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// Retrying would hide how the service is doing, so only make one attempt.
+	resp, err := call.attempt(ctx, 1)
+	result.Latency = time.Since(result.CheckedAt)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	result.Status = resp.StatusCode
+	result.Healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !result.Healthy {
+		result.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+
+	return result
+}
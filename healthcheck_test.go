@@ -0,0 +1,159 @@
+package microservicetransport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker(t *testing.T) {
+	// Start HTTP servers to act as a healthy, a failing and a hanging service.
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer healthy.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	done := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hanging.Close()
+	defer close(done)
+
+	resolver := &StaticResolver{Entries: []StaticEntry{
+		{Name: "healthy", URLs: []string{healthy.URL}},
+		{Name: "failing", URLs: []string{failing.URL}},
+		{Name: "hanging", URLs: []string{hanging.URL}},
+	}}
+	newService := func(name string) *Service {
+		service := NewService(DefaultHttpClient(), "master", "staging", "services", name)
+		service.Resolver = resolver
+		service.Retry = DefaultRetryPolicy()
+
+		return service
+	}
+
+	checker := NewHealthChecker()
+	checker.Resource = "status"
+	checker.Timeout = 100 * time.Millisecond
+	checker.Register(newService("healthy"))
+
+	// Services are unhealthy until they have been checked.
+	if report := checker.Report(); report.Healthy {
+		t.Errorf("TestHealthChecker: expected an unchecked service to be unhealthy")
+	}
+
+	if report := checker.Check(context.Background()); !report.Healthy {
+		t.Errorf("TestHealthChecker: expected healthy got %+v", report)
+	}
+
+	checker.Register(newService("failing"))
+	checker.Register(newService("hanging"))
+
+	start := time.Now()
+	report := checker.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("TestHealthChecker: expected checks to time out, took %v", elapsed)
+	}
+
+	if report.Healthy || len(report.Services) != 3 {
+		t.Fatalf("TestHealthChecker: expected 3 services, unhealthy, got %+v", report)
+	}
+
+	expected := []struct {
+		name    string
+		healthy bool
+		status  int
+		err     string
+	}{
+		{name: "healthy", healthy: true, status: http.StatusOK},
+		{name: "failing", status: http.StatusInternalServerError, err: "unexpected status 500 Internal Server Error"},
+		{name: "hanging", err: "timed out after 100ms"},
+	}
+	for i, result := range report.Services {
+		if result.Name != expected[i].name || result.Healthy != expected[i].healthy ||
+			result.Status != expected[i].status || result.Error != expected[i].err {
+			t.Errorf("TestHealthChecker: expected %+v got %+v", expected[i], result)
+		}
+
+		if result.CheckedAt.IsZero() || result.Latency <= 0 {
+			t.Errorf("TestHealthChecker: expected %s to have been timed got %+v", result.Name, result)
+		}
+	}
+
+	// The report is served as JSON.
+	rec := httptest.NewRecorder()
+	checker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("TestHealthChecker: expected %v got %v", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	var served struct {
+		Healthy  bool `json:"healthy"`
+		Services []struct {
+			Name      string  `json:"name"`
+			LatencyMs float64 `json:"latency_ms"`
+		} `json:"services"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&served); err != nil {
+		t.Fatalf("TestHealthChecker: %s", err)
+	}
+	if served.Healthy || len(served.Services) != 3 || served.Services[2].LatencyMs < 100 {
+		t.Errorf("TestHealthChecker: unexpected report %+v", served)
+	}
+}
+
+func TestHealthChecker_Run(t *testing.T) {
+	// Start a HTTP server to act as a service, counting the checks.
+	checks := make(chan struct{}, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks <- struct{}{}
+	}))
+	defer ts.Close()
+
+	service := NewService(DefaultHttpClient(), "master", "staging", "services", "myservice")
+	service.Resolver = &StaticResolver{Entries: []StaticEntry{{Name: "myservice", URLs: []string{ts.URL}}}}
+
+	checker := NewHealthChecker()
+	checker.Interval = 10 * time.Millisecond
+	checker.Register(service)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		checker.Run(ctx)
+		close(stopped)
+	}()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-checks:
+		case <-time.After(time.Second):
+			t.Fatalf("TestHealthChecker_Run: expected check %d", i+1)
+		}
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("TestHealthChecker_Run: expected the checker to stop")
+	}
+
+	rec := httptest.NewRecorder()
+	checker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"healthy":true`) {
+		t.Errorf("TestHealthChecker_Run: expected a healthy report got %v %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,56 @@
+package microservicetransport
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/LUSHDigital/microservice-transport-golang/config"
+)
+
+// Interceptor - Intercepts every request a call sends to a service, e.g. to
+// add headers, log or record metrics. An interceptor passes the request on by
+// calling next, and sees the response or error which comes back.
+//
+//	func timing(invocation *Invocation, next Invoker) (*http.Response, error) {
+//	    start := time.Now()
+//	    resp, err := next(invocation)
+//	    log.Printf("%s took %s", invocation.Identity, time.Since(start))
+//	    return resp, err
+//	}
+type Interceptor func(invocation *Invocation, next Invoker) (*http.Response, error)
+
+// Invoker - Sends a request on through the rest of the interceptors, and
+// finally to the service.
+type Invoker func(invocation *Invocation) (*http.Response, error)
+
+// Invocation - A single request sent to a service by a call.
+type Invocation struct {
+	Request     *Request      // Request the call was prepared from, nil for calls wrapping a request built elsewhere.
+	HTTPRequest *http.Request // HTTP request to send, which interceptors may change or replace.
+	Identity    Identity      // Identity of the service being called.
+	Attempt     int           // Number of the attempt at the call, starting at 1.
+}
+
+// chain - Build an invoker passing requests through the interceptors in
+// order, and then on to the final invoker.
+func chain(interceptors []Interceptor, final Invoker) Invoker {
+	invoker := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(invocation *Invocation) (*http.Response, error) {
+			return interceptor(invocation, next)
+		}
+	}
+
+	return invoker
+}
+
+// versionHeaderInterceptor - Built-in interceptor adding the version header
+// to requests to versioned services.
+func versionHeaderInterceptor(invocation *Invocation, next Invoker) (*http.Response, error) {
+	if invocation.Identity.Version != 0 {
+		invocation.HTTPRequest.Header.Set(config.ServiceVersionHeader, strconv.Itoa(invocation.Identity.Version))
+	}
+
+	return next(invocation)
+}
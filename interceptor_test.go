@@ -0,0 +1,196 @@
+package microservicetransport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/LUSHDigital/microservice-transport-golang/config"
+	"github.com/LUSHDigital/microservice-transport-golang/models"
+)
+
+func TestService_Interceptors(t *testing.T) {
+	// Start a HTTP server to act as the service, which fails the first call
+	// and only accepts intercepted requests.
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Intercepted") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	var events []string
+	record := func(label string) Interceptor {
+		return func(invocation *Invocation, next Invoker) (*http.Response, error) {
+			events = append(events, fmt.Sprintf("%s start %s %s %d", label, invocation.Identity.Name, invocation.Request.Resource, invocation.Attempt))
+
+			resp, err := next(invocation)
+			if err != nil {
+				t.Fatalf("TestService_Interceptors: %s", err)
+			}
+
+			events = append(events, fmt.Sprintf("%s end %d", label, resp.StatusCode))
+			return resp, err
+		}
+	}
+
+	service := NewService(redirectClient(ts), "master", "staging", "services", "myservice")
+	service.Retry = &RetryPolicy{MaxAttempts: 2, StatusCodes: []int{http.StatusServiceUnavailable}}
+	service.Interceptors = []Interceptor{
+		record("outer"),
+		func(invocation *Invocation, next Invoker) (*http.Response, error) {
+			invocation.HTTPRequest.Header.Set("X-Intercepted", "true")
+			return next(invocation)
+		},
+		record("inner"),
+	}
+
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+		t.Fatalf("TestService_Interceptors: %s", err)
+	}
+
+	resp, err := service.Call()
+	if err != nil {
+		t.Fatalf("TestService_Interceptors: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("TestService_Interceptors: expected status %v got %v", http.StatusOK, resp.StatusCode)
+	}
+
+	expectedEvents := []string{
+		"outer start myservice things 1",
+		"inner start myservice things 1",
+		"inner end 503",
+		"outer end 503",
+		"outer start myservice things 2",
+		"inner start myservice things 2",
+		"inner end 200",
+		"outer end 200",
+	}
+	if !reflect.DeepEqual(events, expectedEvents) {
+		t.Errorf("TestService_Interceptors: expected %v got %v", expectedEvents, events)
+	}
+}
+
+func TestCloudService_Interceptors(t *testing.T) {
+	// Start a fake API gateway, which only accepts the fresh token.
+	ts := newFakeGateway(&models.Token{Type: "random", Value: "fresh"}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(config.AuthHeader) != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	defer ts.Close()
+
+	service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "myservice", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+	service.Version = 2
+	service.GatewayUrls = []string{ts.URL}
+	service.TokenCache = NewTokenCache()
+
+	// Seed the cache with a token the service will reject.
+	service.TokenCache.Get(context.Background(), tokenCacheKey(ts.URL, service.Credentials), func() (*models.Token, error) {
+		return &models.Token{Type: "random", Value: "stale"}, nil
+	})
+
+	// The built-in interceptors run first, so the replay after the stale
+	// token is rejected is seen along with the original request.
+	var seen []string
+	service.Interceptors = []Interceptor{
+		func(invocation *Invocation, next Invoker) (*http.Response, error) {
+			resp, err := next(invocation)
+			seen = append(seen, fmt.Sprintf("%s %s %d",
+				invocation.HTTPRequest.Header.Get(config.AuthHeader),
+				invocation.HTTPRequest.Header.Get(config.ServiceVersionHeader),
+				resp.StatusCode))
+
+			return resp, err
+		},
+	}
+
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+		t.Fatalf("TestCloudService_Interceptors: %s", err)
+	}
+
+	// The headers are set on the request when it is dialled, so it can be
+	// sent with another client.
+	if service.CurrentRequest.Header.Get(config.AuthHeader) != "Bearer stale" || service.CurrentRequest.Header.Get(config.ServiceVersionHeader) != "2" {
+		t.Errorf("TestCloudService_Interceptors: expected auth and version headers got %v", service.CurrentRequest.Header)
+	}
+
+	resp, err := service.Call()
+	if err != nil {
+		t.Fatalf("TestCloudService_Interceptors: %s", err)
+	}
+	resp.Body.Close()
+
+	expectedSeen := []string{"Bearer stale 2 401", "Bearer fresh 2 200"}
+	if !reflect.DeepEqual(seen, expectedSeen) {
+		t.Errorf("TestCloudService_Interceptors: expected %v got %v", expectedSeen, seen)
+	}
+}
+
+func TestCloudService_Interceptors_override(t *testing.T) {
+	// Start a fake API gateway, capturing the headers the service receives.
+	var received http.Header
+	ts := newFakeGateway(testGatewayToken, func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	})
+	defer ts.Close()
+
+	service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "myservice", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+	service.Version = 2
+	service.GatewayUrls = []string{ts.URL}
+	service.TokenCache = NewTokenCache()
+
+	// The built-in interceptors have set the headers by the time those of
+	// the service run, which can then override them.
+	var seen string
+	service.Interceptors = []Interceptor{
+		func(invocation *Invocation, next Invoker) (*http.Response, error) {
+			seen = invocation.HTTPRequest.Header.Get(config.AuthHeader) + " " + invocation.HTTPRequest.Header.Get(config.ServiceVersionHeader)
+			invocation.HTTPRequest.Header.Set(config.ServiceVersionHeader, "3")
+
+			return next(invocation)
+		},
+	}
+
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+		t.Fatalf("TestCloudService_Interceptors_override: %s", err)
+	}
+
+	// Clear the headers of the prepared request, so only the interceptors
+	// can have set them.
+	service.CurrentRequest.Header.Del(config.AuthHeader)
+	service.CurrentRequest.Header.Del(config.ServiceVersionHeader)
+
+	resp, err := service.Call()
+	if err != nil {
+		t.Fatalf("TestCloudService_Interceptors_override: %s", err)
+	}
+	resp.Body.Close()
+
+	expectedSeen := testGatewayToken.PrepareForHttp() + " 2"
+	if seen != expectedSeen {
+		t.Errorf("TestCloudService_Interceptors_override: expected %v got %v", expectedSeen, seen)
+	}
+
+	if received.Get(config.ServiceVersionHeader) != "3" {
+		t.Errorf("TestCloudService_Interceptors_override: expected version 3 got %v", received.Get(config.ServiceVersionHeader))
+	}
+}
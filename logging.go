@@ -0,0 +1,317 @@
+package microservicetransport
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/config"
+)
+
+// LogLevel - Severity of a call log entry.
+type LogLevel int
+
+const (
+	// LogDebug - Log every request, with its headers and JSON body.
+	LogDebug LogLevel = iota
+
+	// LogInfo - Log every request.
+	LogInfo
+
+	// LogError - Only log requests which fail or get a 5xx status.
+	LogError
+)
+
+// redacted - Value logged in place of sensitive values.
+const redacted = "[REDACTED]"
+
+// alwaysSensitiveFields - JSON fields redacted whether or not they are
+// configured, covering the password in API gateway logins.
+var alwaysSensitiveFields = []string{"password"}
+
+// String - Get the name of the level.
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	default:
+		return "error"
+	}
+}
+
+// CallLogger - Writes a JSON line for each request sent to a service and each
+// API gateway login, e.g.
+//
+//	{"time":"2026-10-17T09:00:00Z","level":"info","msg":"call","service":"orders",...}
+//
+// The Authorization header and the password of logins are always redacted,
+// as are the configured query keys and JSON fields. A CallLogger is safe for
+// concurrent use.
+type CallLogger struct {
+	Output             io.Writer // Where entries are written, os.Stderr if nil.
+	Level              LogLevel  // Least severe level logged.
+	SensitiveQueryKeys []string  // Query keys whose values are redacted.
+	SensitiveFields    []string  // JSON body fields whose values are redacted, at any depth.
+
+	mu sync.Mutex
+}
+
+// callLogEntry - A single line written by a call logger.
+type callLogEntry struct {
+	Time          string            `json:"time"`
+	Level         string            `json:"level"`
+	Message       string            `json:"msg"`
+	Service       string            `json:"service"`
+	Namespace     string            `json:"namespace,omitempty"`
+	Version       int               `json:"version,omitempty"`
+	Method        string            `json:"method"`
+	URL           string            `json:"url"`
+	Status        int               `json:"status,omitempty"`
+	DurationMs    float64           `json:"duration_ms"`
+	Attempt       int               `json:"attempt,omitempty"`
+	RequestBytes  int64             `json:"request_bytes"`
+	ResponseBytes *int64            `json:"response_bytes,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          interface{}       `json:"body,omitempty"`
+}
+
+// NewCallLogger - Prepare a new call logger writing entries of the level
+// provided and above.
+func NewCallLogger(output io.Writer, level LogLevel) *CallLogger {
+	return &CallLogger{Output: output, Level: level}
+}
+
+// logCall - Do a request, logging it if there is a logger. Attempts of 0
+// are left out of the entry.
+//
+// The bytes of the request and response bodies are counted as they are
+// read, so requests which get a response are logged once its body has been
+// read to the end or closed.
+func (l *CallLogger) logCall(message string, identity Identity, attempt int, req *http.Request, do func() (*http.Response, error)) (*http.Response, error) {
+	if l == nil {
+		return do()
+	}
+
+	entry := callLogEntry{
+		Message:   message,
+		Service:   identity.Name,
+		Namespace: identity.Namespace,
+		Version:   identity.Version,
+		Method:    req.Method,
+		URL:       l.redactUrl(req),
+		Attempt:   attempt,
+	}
+
+	// Headers and bodies are only read when they are going to be logged.
+	if l.Level <= LogDebug {
+		entry.Headers = redactHeaders(req.Header)
+		entry.Body = l.redactBody(req)
+	}
+
+	// Count the request body as the transport sends it.
+	requestBody := &countingBody{ReadCloser: req.Body}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = requestBody
+	}
+
+	start := time.Now()
+	resp, err := do()
+	entry.DurationMs = float64(time.Since(start)) / float64(time.Millisecond)
+
+	level := LogInfo
+	if err != nil {
+		level = LogError
+		entry.Error = err.Error()
+	} else {
+		entry.Status = resp.StatusCode
+		if resp.StatusCode >= 500 {
+			level = LogError
+		}
+	}
+
+	if level < l.Level {
+		return resp, err
+	}
+	entry.Level = level.String()
+
+	if err != nil {
+		entry.RequestBytes = requestBody.count()
+		l.write(entry)
+
+		return resp, err
+	}
+
+	// Log the call once the response body is done with.
+	responseBody := &countingBody{ReadCloser: resp.Body}
+	responseBody.done = func() {
+		responseBytes := responseBody.count()
+		entry.RequestBytes = requestBody.count()
+		entry.ResponseBytes = &responseBytes
+		l.write(entry)
+	}
+	resp.Body = responseBody
+
+	return resp, err
+}
+
+// countingBody - Counts the bytes read from a body, calling done once when
+// it has been read to the end or closed.
+type countingBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func()
+}
+
+// Read - Read from the body, counting the bytes read.
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.n, int64(n))
+	if err == io.EOF {
+		b.finish()
+	}
+
+	return n, err
+}
+
+// Close - Close the body.
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+
+	return err
+}
+
+// count - Get the number of bytes read so far.
+func (b *countingBody) count() int64 {
+	return atomic.LoadInt64(&b.n)
+}
+
+// finish - Call done if it is set and has not been called yet.
+func (b *countingBody) finish() {
+	if b.done != nil {
+		b.once.Do(b.done)
+	}
+}
+
+// write - Write an entry as a line of JSON.
+func (l *CallLogger) write(entry callLogEntry) {
+	entry.Time = time.Now().UTC().Format(time.RFC3339Nano)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	output := l.Output
+	if output == nil {
+		output = os.Stderr
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	output.Write(append(line, '\n'))
+}
+
+// redactUrl - Get the URL of a request with the sensitive query values redacted.
+func (l *CallLogger) redactUrl(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+
+	query := u.Query()
+	changed := false
+	for key := range query {
+		if containsFold(l.SensitiveQueryKeys, key) {
+			query.Set(key, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
+
+// redactBody - Get the JSON body of a request with the sensitive fields
+// redacted, or nil if the body cannot be read again or is not JSON.
+func (l *CallLogger) redactBody(req *http.Request) interface{} {
+	if req.GetBody == nil || req.ContentLength == 0 {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+
+	raw, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil
+	}
+
+	fields := append(append([]string{}, alwaysSensitiveFields...), l.SensitiveFields...)
+
+	return redactFields(value, fields)
+}
+
+// redactHeaders - Get the headers of a request with the auth header redacted.
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for key, values := range header {
+		headers[key] = strings.Join(values, ", ")
+	}
+
+	if _, ok := headers[config.AuthHeader]; ok {
+		headers[config.AuthHeader] = redacted
+	}
+
+	return headers
+}
+
+// redactFields - Redact the sensitive fields of a decoded JSON value, at any depth.
+func redactFields(value interface{}, fields []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if containsFold(fields, key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactFields(child, fields)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactFields(child, fields)
+		}
+	}
+
+	return value
+}
+
+// containsFold - Check whether a list holds a value, ignoring case.
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,195 @@
+package microservicetransport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/LUSHDigital/microservice-transport-golang/config"
+	"github.com/LUSHDigital/microservice-transport-golang/models"
+)
+
+func TestCloudService_Logger(t *testing.T) {
+	// Start a fake API gateway, failing every call to the broken resource.
+	ts := newFakeGateway(&models.Token{Type: "random", Value: "secret-token"}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/services/payments/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	defer ts.Close()
+
+	tt := []struct {
+		name            string
+		level           LogLevel
+		resource        string
+		expectedEntries []map[string]interface{}
+	}{
+		{
+			name:     "Debug",
+			level:    LogDebug,
+			resource: "charges",
+			expectedEntries: []map[string]interface{}{
+				{
+					"level":   "info",
+					"msg":     "api gateway login",
+					"service": "api-gateway",
+					"method":  "POST",
+					"url":     ts.URL + "/login",
+					"status":  float64(200),
+					"body":    map[string]interface{}{"email": "test@test.com", "password": redacted},
+				},
+				{
+					"level":          "info",
+					"msg":            "call",
+					"service":        "payments",
+					"method":         "POST",
+					"url":            ts.URL + "/services/payments/charges?" + url.Values{"api_key": {redacted}, "page": {"1"}}.Encode(),
+					"status":         float64(200),
+					"attempt":        float64(1),
+					"request_bytes":  float64(len(`{"amount":10,"card":{"number":"4111"}}`)),
+					"response_bytes": float64(2),
+					"body":           map[string]interface{}{"amount": float64(10), "card": map[string]interface{}{"number": redacted}},
+				},
+			},
+		},
+		{
+			name:     "Errors only",
+			level:    LogError,
+			resource: "broken",
+			expectedEntries: []map[string]interface{}{
+				{
+					"level":   "error",
+					"msg":     "call",
+					"service": "payments",
+					"status":  float64(502),
+					"body":    nil,
+					"headers": nil,
+				},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			logger := NewCallLogger(output, tc.level)
+			logger.SensitiveQueryKeys = []string{"api_key"}
+			logger.SensitiveFields = []string{"number"}
+
+			service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "payments", &AuthCredentials{
+				Email:    "test@test.com",
+				Password: "secret-password",
+			})
+			service.GatewayUrls = []string{ts.URL}
+			service.TokenCache = NewTokenCache()
+			service.Logger = logger
+
+			err := service.Dial(&Request{
+				Method:   http.MethodPost,
+				Resource: tc.resource,
+				Query:    url.Values{"api_key": {"secret-key"}, "page": {"1"}},
+				Body:     ioutil.NopCloser(strings.NewReader(`{"amount":10,"card":{"number":"4111"}}`)),
+			})
+			if err != nil {
+				t.Fatalf("TestCloudService_Logger: %s: %s", tc.name, err)
+			}
+
+			resp, err := service.Call()
+			if err != nil {
+				t.Fatalf("TestCloudService_Logger: %s: %s", tc.name, err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			for _, secret := range []string{"secret-password", "secret-token", "secret-key", "4111"} {
+				if strings.Contains(output.String(), secret) {
+					t.Errorf("TestCloudService_Logger: %s: expected %v to be redacted in %v", tc.name, secret, output.String())
+				}
+			}
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			if len(lines) != len(tc.expectedEntries) {
+				t.Fatalf("TestCloudService_Logger: %s: expected %d entries got %v", tc.name, len(tc.expectedEntries), lines)
+			}
+
+			for i, line := range lines {
+				entry := map[string]interface{}{}
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("TestCloudService_Logger: %s: %s", tc.name, err)
+				}
+
+				for key, value := range tc.expectedEntries[i] {
+					actual, _ := json.Marshal(entry[key])
+					expected, _ := json.Marshal(value)
+					if string(actual) != string(expected) {
+						t.Errorf("TestCloudService_Logger: %s: expected %s %s got %s", tc.name, key, expected, actual)
+					}
+				}
+
+				if _, ok := entry["duration_ms"]; !ok {
+					t.Errorf("TestCloudService_Logger: %s: expected a duration in %v", tc.name, line)
+				}
+			}
+
+			// The auth header is logged, but never its value.
+			if tc.level == LogDebug && !strings.Contains(lines[1], `"`+config.AuthHeader+`":"`+redacted+`"`) {
+				t.Errorf("TestCloudService_Logger: %s: expected a redacted auth header in %v", tc.name, lines[1])
+			}
+		})
+	}
+}
+
+func TestService_Logger_bytes(t *testing.T) {
+	// Start a HTTP server to act as the service, streaming its response in
+	// chunks so it has no content length.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			w.Write([]byte(`{"chunk":1}`))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+
+	output := &bytes.Buffer{}
+	service := NewService(redirectClient(ts), "master", "staging", "services", "orders")
+	service.Logger = NewCallLogger(output, LogInfo)
+
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "orders"}); err != nil {
+		t.Fatalf("TestService_Logger_bytes: %s", err)
+	}
+
+	resp, err := service.Call()
+	if err != nil {
+		t.Fatalf("TestService_Logger_bytes: %s", err)
+	}
+	if resp.ContentLength != -1 {
+		t.Fatalf("TestService_Logger_bytes: expected a chunked response got length %d", resp.ContentLength)
+	}
+
+	// Nothing is logged until the body has been read.
+	if output.Len() != 0 {
+		t.Errorf("TestService_Logger_bytes: expected no entries yet got %v", output.String())
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+		t.Fatalf("TestService_Logger_bytes: %s", err)
+	}
+
+	if entry["request_bytes"] != float64(0) {
+		t.Errorf("TestService_Logger_bytes: expected request_bytes 0 got %v", entry["request_bytes"])
+	}
+	if entry["response_bytes"] != float64(len(body)) || len(body) != 3*len(`{"chunk":1}`) {
+		t.Errorf("TestService_Logger_bytes: expected response_bytes %d got %v", len(body), entry["response_bytes"])
+	}
+}
@@ -0,0 +1,300 @@
+package microservicetransport
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets - Upper bounds in seconds of the latency histogram
+// buckets used by metrics which do not set their own.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricLabels - Identifies the series a request to a service is recorded in.
+type MetricLabels struct {
+	Name      string // Name of the service, or api-gateway for logins.
+	Namespace string // Namespace of the service.
+	Version   int    // Major API version of the service.
+	Method    string // HTTP method of the request.
+	Resource  string // Template of the resource requested, e.g. orders/{id}.
+	Endpoint  string // Host the request was sent to.
+}
+
+// MetricsCollector - Records metrics for the requests sent to services,
+// e.g. to feed them to a metrics backend. Collectors must be safe for
+// concurrent use.
+type MetricsCollector interface {
+	// RequestStarted - Record a request being sent.
+	RequestStarted(labels MetricLabels)
+
+	// RequestFinished - Record the outcome of a request, with a status of 0
+	// if it failed without a response.
+	RequestFinished(labels MetricLabels, status int, duration time.Duration, err error)
+}
+
+// Metrics - Collects request counts, latency histograms, in-flight gauges and
+// error counts in memory, and serves them in the Prometheus text format.
+//
+//	metrics := NewMetrics()
+//	service.Metrics = metrics
+//	http.Handle("/metrics", metrics)
+type Metrics struct {
+	Buckets []float64 // Upper bounds in seconds of the latency buckets of new series, DefaultLatencyBuckets if nil.
+
+	mu        sync.Mutex
+	requests  map[statusSeries]uint64
+	errors    map[statusSeries]uint64
+	inFlight  map[MetricLabels]int64
+	latencies map[MetricLabels]*histogram
+}
+
+// statusSeries - Identifies the series of requests which finished with a
+// class of status.
+type statusSeries struct {
+	MetricLabels
+	statusClass string
+}
+
+// histogram - Counts of the latencies observed for a series.
+type histogram struct {
+	bounds []float64 // Upper bounds of the buckets, fixed when the series is first observed.
+	counts []uint64  // Count of the latencies in each bucket, not cumulative.
+	count  uint64
+	sum    float64
+}
+
+// NewMetrics - Prepare new metrics with the default latency buckets.
+func NewMetrics() *Metrics {
+	return &Metrics{Buckets: DefaultLatencyBuckets}
+}
+
+// RequestStarted - Count a request as in flight.
+func (m *Metrics) RequestStarted(labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.inFlight == nil {
+		m.inFlight = make(map[MetricLabels]int64)
+	}
+	m.inFlight[labels]++
+}
+
+// RequestFinished - Count a request and its latency, and count it as an
+// error if it failed or the service responded with a 5xx status.
+func (m *Metrics) RequestFinished(labels MetricLabels, status int, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.requests == nil {
+		m.requests = make(map[statusSeries]uint64)
+		m.errors = make(map[statusSeries]uint64)
+		m.latencies = make(map[MetricLabels]*histogram)
+	}
+
+	series := statusSeries{labels, statusClass(status, err)}
+	m.requests[series]++
+	if err != nil || status >= 500 {
+		m.errors[series]++
+	}
+
+	if m.inFlight[labels] > 0 {
+		m.inFlight[labels]--
+	}
+
+	h, ok := m.latencies[labels]
+	if !ok {
+		bounds := append([]float64(nil), m.buckets()...)
+		h = &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+		m.latencies[labels] = h
+	}
+
+	seconds := duration.Seconds()
+	h.count++
+	h.sum += seconds
+	for i, bound := range h.bounds {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+}
+
+// ServeHTTP - Serve the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo - Write the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, m.text())
+	return int64(n), err
+}
+
+// text - Get the metrics in the Prometheus text format. The lock is only held
+// while they are formatted, so slow writers do not hold up requests.
+func (m *Metrics) text() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := &strings.Builder{}
+
+	b.WriteString("# HELP microservicetransport_requests_total Requests sent to services.\n")
+	b.WriteString("# TYPE microservicetransport_requests_total counter\n")
+	for _, series := range sortedStatusSeries(m.requests) {
+		fmt.Fprintf(b, "microservicetransport_requests_total{%s} %d\n", series.labels(), m.requests[series])
+	}
+
+	b.WriteString("# HELP microservicetransport_request_errors_total Requests to services which failed or got a 5xx status.\n")
+	b.WriteString("# TYPE microservicetransport_request_errors_total counter\n")
+	for _, series := range sortedStatusSeries(m.errors) {
+		fmt.Fprintf(b, "microservicetransport_request_errors_total{%s} %d\n", series.labels(), m.errors[series])
+	}
+
+	b.WriteString("# HELP microservicetransport_requests_in_flight Requests to services waiting for a response.\n")
+	b.WriteString("# TYPE microservicetransport_requests_in_flight gauge\n")
+	for _, labels := range sortedLabels(m.inFlight) {
+		fmt.Fprintf(b, "microservicetransport_requests_in_flight{%s} %d\n", labels.labels(), m.inFlight[labels])
+	}
+
+	b.WriteString("# HELP microservicetransport_request_duration_seconds Latency of requests to services.\n")
+	b.WriteString("# TYPE microservicetransport_request_duration_seconds histogram\n")
+	latencyLabels := make([]MetricLabels, 0, len(m.latencies))
+	for labels := range m.latencies {
+		latencyLabels = append(latencyLabels, labels)
+	}
+	sortMetricLabels(latencyLabels)
+	for _, labels := range latencyLabels {
+		h := m.latencies[labels]
+
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "microservicetransport_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels.labels(), formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(b, "microservicetransport_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels.labels(), h.count)
+		fmt.Fprintf(b, "microservicetransport_request_duration_seconds_sum{%s} %s\n", labels.labels(), formatFloat(h.sum))
+		fmt.Fprintf(b, "microservicetransport_request_duration_seconds_count{%s} %d\n", labels.labels(), h.count)
+	}
+
+	return b.String()
+}
+
+// buckets - Get the upper bounds of the latency buckets.
+func (m *Metrics) buckets() []float64 {
+	if m.Buckets != nil {
+		return m.Buckets
+	}
+
+	return DefaultLatencyBuckets
+}
+
+// labels - Get the labels of a series in the Prometheus text format.
+func (l MetricLabels) labels() string {
+	return fmt.Sprintf(`name="%s",namespace="%s",version="%d",method="%s",resource="%s",endpoint="%s"`,
+		escapeLabel(l.Name), escapeLabel(l.Namespace), l.Version, escapeLabel(l.Method), escapeLabel(l.Resource), escapeLabel(l.Endpoint))
+}
+
+// labels - Get the labels of a series in the Prometheus text format.
+func (s statusSeries) labels() string {
+	return fmt.Sprintf(`%s,status_class="%s"`, s.MetricLabels.labels(), s.statusClass)
+}
+
+// statusClass - Get the class of a response status, e.g. 2xx, or error for
+// requests which failed without a response.
+func statusClass(status int, err error) string {
+	if err != nil || status < 100 {
+		return "error"
+	}
+
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// sortedStatusSeries - Get the series of a counter, in order.
+func sortedStatusSeries(counter map[statusSeries]uint64) []statusSeries {
+	series := make([]statusSeries, 0, len(counter))
+	for s := range counter {
+		series = append(series, s)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].labels() < series[j].labels()
+	})
+
+	return series
+}
+
+// sortedLabels - Get the series of a gauge, in order.
+func sortedLabels(gauge map[MetricLabels]int64) []MetricLabels {
+	labels := make([]MetricLabels, 0, len(gauge))
+	for l := range gauge {
+		labels = append(labels, l)
+	}
+	sortMetricLabels(labels)
+
+	return labels
+}
+
+// sortMetricLabels - Put series in order.
+func sortMetricLabels(labels []MetricLabels) {
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].labels() < labels[j].labels()
+	})
+}
+
+// escapeLabel - Escape a label value for the Prometheus text format.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatFloat - Format a sample value for the Prometheus text format.
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// idSegmentPattern - Pattern of resource path segments holding IDs, i.e.
+// numbers, UUIDs and long hex strings.
+var idSegmentPattern = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{24,})$`)
+
+// resourceTemplate - Get the template of a resource, replacing the segments
+// holding IDs with {id}, e.g. orders/42/items becomes orders/{id}/items.
+func resourceTemplate(resource string) string {
+	segments := strings.Split(resource, "/")
+	for i, segment := range segments {
+		if idSegmentPattern.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// collectMetrics - Do a request, recording it with the collector if there is one.
+func collectMetrics(collector MetricsCollector, labels MetricLabels, do func() (*http.Response, error)) (*http.Response, error) {
+	if collector == nil {
+		return do()
+	}
+
+	collector.RequestStarted(labels)
+	start := time.Now()
+
+	resp, err := do()
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	collector.RequestFinished(labels, status, time.Since(start), err)
+
+	return resp, err
+}
@@ -0,0 +1,217 @@
+package microservicetransport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/models"
+)
+
+func TestResourceTemplate(t *testing.T) {
+	tt := []struct {
+		name             string
+		resource         string
+		expectedTemplate string
+	}{
+		{
+			name:             "No ids",
+			resource:         "orders",
+			expectedTemplate: "orders",
+		},
+		{
+			name:             "Numeric id",
+			resource:         "orders/42/items",
+			expectedTemplate: "orders/{id}/items",
+		},
+		{
+			name:             "UUID",
+			resource:         "orders/3f2a1c9e-4b7d-4e8a-9c1f-2d3e4f5a6b7c",
+			expectedTemplate: "orders/{id}",
+		},
+		{
+			name:             "Object id",
+			resource:         "orders/507f1f77bcf86cd799439011",
+			expectedTemplate: "orders/{id}",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actualTemplate := resourceTemplate(tc.resource)
+			if actualTemplate != tc.expectedTemplate {
+				t.Errorf("TestResourceTemplate: %s: expected %v got %v", tc.name, tc.expectedTemplate, actualTemplate)
+			}
+		})
+	}
+}
+
+func TestMetrics_RequestFinished(t *testing.T) {
+	metrics := &Metrics{Buckets: []float64{0.1, 1}}
+	labels := MetricLabels{Name: "orders", Namespace: "services", Method: http.MethodGet, Resource: "orders", Endpoint: "orders"}
+
+	metrics.RequestStarted(labels)
+	metrics.RequestStarted(labels)
+	metrics.RequestFinished(labels, http.StatusOK, 50*time.Millisecond, nil)
+
+	output := &strings.Builder{}
+	metrics.WriteTo(output)
+
+	series := `name="orders",namespace="services",version="0",method="GET",resource="orders",endpoint="orders"`
+	expectedLines := []string{
+		fmt.Sprintf(`microservicetransport_requests_total{%s,status_class="2xx"} 1`, series),
+		fmt.Sprintf(`microservicetransport_requests_in_flight{%s} 1`, series),
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_bucket{%s,le="0.1"} 1`, series),
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_bucket{%s,le="1"} 1`, series),
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_bucket{%s,le="+Inf"} 1`, series),
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_count{%s} 1`, series),
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output.String(), line+"\n") {
+			t.Errorf("TestMetrics_RequestFinished: expected %v in %v", line, output.String())
+		}
+	}
+
+	metrics.RequestFinished(labels, 0, 2*time.Second, errors.New("connection refused"))
+
+	output.Reset()
+	metrics.WriteTo(output)
+
+	expectedLines = []string{
+		fmt.Sprintf(`microservicetransport_requests_total{%s,status_class="error"} 1`, series),
+		fmt.Sprintf(`microservicetransport_request_errors_total{%s,status_class="error"} 1`, series),
+		fmt.Sprintf(`microservicetransport_requests_in_flight{%s} 0`, series),
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_bucket{%s,le="1"} 1`, series),
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_bucket{%s,le="+Inf"} 2`, series),
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output.String(), line+"\n") {
+			t.Errorf("TestMetrics_RequestFinished: expected %v in %v", line, output.String())
+		}
+	}
+}
+
+func TestMetrics_Buckets(t *testing.T) {
+	metrics := &Metrics{Buckets: []float64{0.1}}
+	orders := MetricLabels{Name: "orders", Namespace: "services", Method: http.MethodGet, Resource: "orders", Endpoint: "orders"}
+	payments := MetricLabels{Name: "payments", Namespace: "services", Method: http.MethodGet, Resource: "payments", Endpoint: "payments"}
+
+	metrics.RequestFinished(orders, http.StatusOK, 50*time.Millisecond, nil)
+
+	// Series already observed keep their buckets, new series use the new ones.
+	metrics.Buckets = []float64{0.1, 1, 10}
+	metrics.RequestFinished(orders, http.StatusOK, 5*time.Second, nil)
+	metrics.RequestFinished(payments, http.StatusOK, 5*time.Second, nil)
+
+	output := &strings.Builder{}
+	metrics.WriteTo(output)
+
+	expectedLines := []string{
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_bucket{%s,le="0.1"} 1`, orders.labels()),
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_bucket{%s,le="+Inf"} 2`, orders.labels()),
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_bucket{%s,le="10"} 1`, payments.labels()),
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output.String(), line+"\n") {
+			t.Errorf("TestMetrics_Buckets: expected %v in %v", line, output.String())
+		}
+	}
+
+	unexpected := fmt.Sprintf(`microservicetransport_request_duration_seconds_bucket{%s,le="10"}`, orders.labels())
+	if strings.Contains(output.String(), unexpected) {
+		t.Errorf("TestMetrics_Buckets: expected no %v in %v", unexpected, output.String())
+	}
+}
+
+// recordingWriter - Writer recording a request with the metrics it is
+// writing, as a slow scrape would race with calls.
+type recordingWriter struct {
+	strings.Builder
+	metrics *Metrics
+}
+
+// Write - Record a request, then write the metrics.
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.metrics.RequestStarted(MetricLabels{Name: "orders"})
+
+	return w.Builder.Write(p)
+}
+
+func TestMetrics_WriteTo_unlocked(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.RequestStarted(MetricLabels{Name: "orders"})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		metrics.WriteTo(&recordingWriter{metrics: metrics})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TestMetrics_WriteTo_unlocked: writing the metrics held the lock")
+	}
+}
+
+func TestCloudService_Metrics(t *testing.T) {
+	// Start a fake API gateway, failing every call for order 43.
+	ts := newFakeGateway(&models.Token{Type: "random", Value: "token"}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/services/orders/orders/43" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	defer ts.Close()
+
+	metrics := NewMetrics()
+	service := NewCloudService(DefaultHttpClient(), "master", "staging", "services", "orders", &AuthCredentials{
+		Email:    "test@test.com",
+		Password: "1234",
+	})
+	service.Version = 1
+	service.GatewayUrls = []string{ts.URL}
+	service.TokenCache = NewTokenCache()
+	service.Metrics = metrics
+
+	for _, resource := range []string{"orders/42", "orders/43"} {
+		if err := service.Dial(&Request{Method: http.MethodGet, Resource: resource}); err != nil {
+			t.Fatalf("TestCloudService_Metrics: %s", err)
+		}
+
+		resp, err := service.Call()
+		if err != nil {
+			t.Fatalf("TestCloudService_Metrics: %s", err)
+		}
+		resp.Body.Close()
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("TestCloudService_Metrics: expected text/plain got %v", w.Header().Get("Content-Type"))
+	}
+
+	gateway, _ := url.Parse(ts.URL)
+	orders := fmt.Sprintf(`name="orders",namespace="services",version="1",method="GET",resource="orders/{id}",endpoint="%s"`, gateway.Host)
+	login := fmt.Sprintf(`name="api-gateway",namespace="",version="0",method="POST",resource="login",endpoint="%s"`, gateway.Host)
+
+	expectedLines := []string{
+		fmt.Sprintf(`microservicetransport_requests_total{%s,status_class="2xx"} 1`, login),
+		fmt.Sprintf(`microservicetransport_requests_total{%s,status_class="2xx"} 1`, orders),
+		fmt.Sprintf(`microservicetransport_requests_total{%s,status_class="5xx"} 1`, orders),
+		fmt.Sprintf(`microservicetransport_request_errors_total{%s,status_class="5xx"} 1`, orders),
+		fmt.Sprintf(`microservicetransport_requests_in_flight{%s} 0`, orders),
+		fmt.Sprintf(`microservicetransport_request_duration_seconds_count{%s} 2`, orders),
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("TestCloudService_Metrics: expected %v in %v", line, w.Body.String())
+		}
+	}
+}
@@ -1,9 +1,19 @@
 package models
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/LUSHDigital/microservice-transport-golang/config"
 )
 
+// TokenTypeJWT - Token type for JSON web tokens.
+const TokenTypeJWT = "JWT"
+
 // Token - An authentication token.
 type Token struct {
 	Type  string `json:"type"`  // The type of auth token (e.g. JWT).
@@ -14,3 +24,35 @@ type Token struct {
 func (t *Token) PrepareForHttp() string {
 	return config.AuthHeaderPrefix + t.Value
 }
+
+// GetExpiry - Get the time the token expires at.
+//
+// Only JWTs carry an expiry, which is read from the unverified "exp" claim.
+func (t *Token) GetExpiry() (time.Time, error) {
+	if t.Type != TokenTypeJWT {
+		return time.Time{}, fmt.Errorf("cannot determine expiry of %q token", t.Type)
+	}
+
+	segments := strings.Split(t.Value, ".")
+	if len(segments) != 3 {
+		return time.Time{}, errors.New("malformed jwt: expected 3 segments")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segments[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed jwt payload: %s", err)
+	}
+
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("malformed jwt claims: %s", err)
+	}
+
+	if claims.Exp == nil {
+		return time.Time{}, errors.New("jwt has no exp claim")
+	}
+
+	return time.Unix(int64(*claims.Exp), 0), nil
+}
@@ -1,8 +1,10 @@
 package models
 
 import (
+	"encoding/base64"
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestToken_PrepareForHttp(t *testing.T) {
@@ -65,3 +67,69 @@ func ExampleToken_PrepareForHttp() {
 
 	// Output: Bearer xxxxxx.xxxxxx.xxxxxx
 }
+
+func TestToken_GetExpiry(t *testing.T) {
+	encode := func(claims string) string {
+		return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+	}
+
+	tt := []struct {
+		name           string
+		token          Token
+		expectedExpiry time.Time
+		expectedErr    bool
+	}{
+		{
+			name: "JWT with exp",
+			token: Token{
+				Type:  "JWT",
+				Value: encode(`{"sub":"1234","exp":1500000000}`),
+			},
+			expectedExpiry: time.Unix(1500000000, 0),
+		},
+		{
+			name: "JWT without exp",
+			token: Token{
+				Type:  "JWT",
+				Value: encode(`{"sub":"1234"}`),
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Malformed JWT",
+			token: Token{
+				Type:  "JWT",
+				Value: "sdfsdfsdfdsfsdf.sdfsdfdsfsdfsdf",
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Random token",
+			token: Token{
+				Type:  "random",
+				Value: encode(`{"exp":1500000000}`),
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			expiry, err := tc.token.GetExpiry()
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("TestToken_GetExpiry: %s: expected an error", tc.name)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("TestToken_GetExpiry: %s: %s", tc.name, err)
+			}
+
+			if !expiry.Equal(tc.expectedExpiry) {
+				t.Errorf("TestToken_GetExpiry: %s: expected %v got %v", tc.name, tc.expectedExpiry, expiry)
+			}
+		})
+	}
+}
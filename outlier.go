@@ -0,0 +1,184 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OutlierPolicy - Decides when an instance of a service is ejected from the
+// pool calls are balanced across, and for how long.
+//
+// An ejected instance is put back into the pool once its ejection ends, and
+// the next attempt sent to it acts as a probe: a success clears its record,
+// while a failure ejects it again for twice as long, up to MaxEjection if it
+// is set.
+type OutlierPolicy struct {
+	ConsecutiveFailures int           // Consecutive 5xx responses or connection errors which eject an instance.
+	BaseEjection        time.Duration // How long an instance is first ejected for.
+	MaxEjection         time.Duration // Longest an instance is ejected for, no limit if 0.
+}
+
+// DefaultOutlierPolicy - Get an outlier policy which ejects an instance after
+// 5 consecutive failures, for 30 seconds at first and 5 minutes at most.
+func DefaultOutlierPolicy() *OutlierPolicy {
+	return &OutlierPolicy{
+		ConsecutiveFailures: 5,
+		BaseEjection:        30 * time.Second,
+		MaxEjection:         5 * time.Minute,
+	}
+}
+
+// outlierDetector - Tracks the health of the instances of a single service
+// identity, by host.
+type outlierDetector struct {
+	mu        sync.Mutex
+	instances map[string]*instanceHealth
+}
+
+// instanceHealth - Health of a single instance of a service.
+type instanceHealth struct {
+	failures     int       // Consecutive failures.
+	ejections    int       // Consecutive ejections, which grow the ejection time.
+	ejectedUntil time.Time // When the current ejection ends.
+	probing      bool      // Whether the instance is back in the pool after an ejection.
+}
+
+// outlierDetectors - Outlier detectors for every service identity in the process.
+var outlierDetectors = struct {
+	sync.Mutex
+	detectors map[Identity]*outlierDetector
+}{detectors: make(map[Identity]*outlierDetector)}
+
+// outlierDetectorFor - Get the outlier detector for a service identity.
+func outlierDetectorFor(identity Identity) *outlierDetector {
+	outlierDetectors.Lock()
+	defer outlierDetectors.Unlock()
+
+	detector, ok := outlierDetectors.detectors[identity]
+	if !ok {
+		detector = &outlierDetector{instances: make(map[string]*instanceHealth)}
+		outlierDetectors.detectors[identity] = detector
+	}
+
+	return detector
+}
+
+// GetEjectedHosts - Get the hosts of the instances of the service which are
+// currently ejected, in order.
+func (s *Service) GetEjectedHosts() []string {
+	if s.OutlierDetection == nil {
+		return nil
+	}
+
+	detector := outlierDetectorFor(s.GetIdentity())
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	hosts := []string{}
+	for host, health := range detector.instances {
+		if time.Now().Before(health.ejectedUntil) {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+// available - Get the endpoints whose instances are not ejected. If every
+// instance is ejected, all endpoints are returned rather than failing calls
+// outright.
+func (d *outlierDetector) available(endpoints []*url.URL) []*url.URL {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	available := make([]*url.URL, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		health, ok := d.instances[endpoint.Host]
+		if !ok {
+			available = append(available, endpoint)
+			continue
+		}
+
+		if health.ejectedUntil.IsZero() {
+			available = append(available, endpoint)
+			continue
+		}
+
+		// Put the instance back into the pool once its ejection ends.
+		if !time.Now().Before(health.ejectedUntil) {
+			health.ejectedUntil = time.Time{}
+			health.probing = true
+			available = append(available, endpoint)
+		}
+	}
+
+	if len(available) == 0 {
+		return endpoints
+	}
+
+	return available
+}
+
+// record - Record the outcome of an attempt sent to an endpoint.
+func (d *outlierDetector) record(policy *OutlierPolicy, endpoint *url.URL, resp *http.Response, err error) {
+	// The caller giving up says nothing about the instance.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	health, ok := d.instances[endpoint.Host]
+	if !ok {
+		health = &instanceHealth{}
+		d.instances[endpoint.Host] = health
+	}
+
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	if !failed {
+		health.failures = 0
+		if health.probing {
+			health.probing = false
+			health.ejections = 0
+		}
+		return
+	}
+
+	health.failures++
+	if health.probing || health.failures >= policy.ConsecutiveFailures {
+		health.eject(policy)
+	}
+}
+
+// ejection - Get how long an instance is ejected for after the provided
+// number of earlier consecutive ejections.
+func (p *OutlierPolicy) ejection(ejections int) time.Duration {
+	ejection := p.BaseEjection << uint(ejections)
+
+	// Doubling for long enough overflows, so hold the ejection at its longest.
+	if ejections > 63 || ejection>>uint(ejections) != p.BaseEjection {
+		ejection = math.MaxInt64
+	}
+
+	if p.MaxEjection > 0 && ejection > p.MaxEjection {
+		ejection = p.MaxEjection
+	}
+
+	return ejection
+}
+
+// eject - Eject the instance for a period growing with each consecutive ejection.
+func (h *instanceHealth) eject(policy *OutlierPolicy) {
+	h.ejectedUntil = time.Now().Add(policy.ejection(h.ejections))
+	h.ejections++
+	h.failures = 0
+	h.probing = false
+}
@@ -0,0 +1,186 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutlierDetector(t *testing.T) {
+	policy := &OutlierPolicy{
+		ConsecutiveFailures: 2,
+		BaseEjection:        time.Minute,
+		MaxEjection:         3 * time.Minute,
+	}
+	detector := &outlierDetector{instances: make(map[string]*instanceHealth)}
+	endpoints := testEndpoints("a", "b")
+	failure := &http.Response{StatusCode: http.StatusServiceUnavailable}
+	success := &http.Response{StatusCode: http.StatusOK}
+
+	// A success in between failures keeps the instance in the pool.
+	detector.record(policy, endpoints[0], failure, nil)
+	detector.record(policy, endpoints[0], success, nil)
+	detector.record(policy, endpoints[0], nil, errors.New("connection refused"))
+	detector.record(policy, endpoints[0], nil, context.Canceled)
+	if available := detector.available(endpoints); len(available) != 2 {
+		t.Fatalf("TestOutlierDetector: expected 2 available endpoints got %v", available)
+	}
+
+	// Consecutive failures eject it.
+	detector.record(policy, endpoints[0], failure, nil)
+	available := detector.available(endpoints)
+	if len(available) != 1 || available[0].Host != "b" {
+		t.Fatalf("TestOutlierDetector: expected only b to be available got %v", available)
+	}
+
+	// Each failed probe ejects it for longer, up to the maximum.
+	health := detector.instances["a"]
+	for _, expected := range []time.Duration{2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		health.ejectedUntil = time.Now().Add(-time.Second)
+		if available := detector.available(endpoints); len(available) != 2 {
+			t.Fatalf("TestOutlierDetector: expected a to be probed got %v", available)
+		}
+
+		detector.record(policy, endpoints[0], failure, nil)
+		if ejection := time.Until(health.ejectedUntil).Round(time.Minute); ejection != expected {
+			t.Errorf("TestOutlierDetector: expected ejection for %v got %v", expected, ejection)
+		}
+	}
+
+	// If every instance is ejected, calls go to all of them.
+	detector.record(policy, endpoints[1], failure, nil)
+	detector.record(policy, endpoints[1], failure, nil)
+	if available := detector.available(endpoints); len(available) != 2 {
+		t.Errorf("TestOutlierDetector: expected 2 available endpoints got %v", available)
+	}
+
+	// A successful probe clears the record of the instance.
+	health.ejectedUntil = time.Now().Add(-time.Second)
+	detector.available(endpoints)
+	detector.record(policy, endpoints[0], success, nil)
+	if health.ejections != 0 || health.probing {
+		t.Errorf("TestOutlierDetector: expected a cleared record got %+v", health)
+	}
+}
+
+func TestOutlierPolicy_ejection(t *testing.T) {
+	tt := []struct {
+		name             string
+		policy           OutlierPolicy
+		ejections        int
+		expectedEjection time.Duration
+	}{
+		{
+			name:             "First ejection",
+			policy:           OutlierPolicy{BaseEjection: time.Minute, MaxEjection: 5 * time.Minute},
+			expectedEjection: time.Minute,
+		},
+		{
+			name:             "Doubled",
+			policy:           OutlierPolicy{BaseEjection: time.Minute, MaxEjection: 5 * time.Minute},
+			ejections:        2,
+			expectedEjection: 4 * time.Minute,
+		},
+		{
+			name:             "Capped",
+			policy:           OutlierPolicy{BaseEjection: time.Minute, MaxEjection: 5 * time.Minute},
+			ejections:        3,
+			expectedEjection: 5 * time.Minute,
+		},
+		{
+			name:             "Capped overflowing",
+			policy:           OutlierPolicy{BaseEjection: time.Minute, MaxEjection: 5 * time.Minute},
+			ejections:        40,
+			expectedEjection: 5 * time.Minute,
+		},
+		{
+			name:             "No cap",
+			policy:           OutlierPolicy{BaseEjection: time.Minute},
+			ejections:        10,
+			expectedEjection: 1024 * time.Minute,
+		},
+		{
+			name:             "No cap overflowing",
+			policy:           OutlierPolicy{BaseEjection: time.Minute},
+			ejections:        40,
+			expectedEjection: math.MaxInt64,
+		},
+		{
+			name:             "No cap far past overflowing",
+			policy:           OutlierPolicy{BaseEjection: time.Minute},
+			ejections:        100,
+			expectedEjection: math.MaxInt64,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actualEjection := tc.policy.ejection(tc.ejections)
+			if actualEjection != tc.expectedEjection {
+				t.Errorf("TestOutlierPolicy_ejection: %s: expected %v got %v", tc.name, tc.expectedEjection, actualEjection)
+			}
+		})
+	}
+}
+
+func TestService_Call_outlierDetection(t *testing.T) {
+	// Start two HTTP servers to act as instances of the service, one of
+	// which always fails.
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "healthy")
+	}))
+	defer healthy.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	service := NewService(DefaultHttpClient(), "master", "staging", "services", "outliers")
+	service.Resolver = &StaticResolver{Entries: []StaticEntry{
+		{Name: "outliers", URLs: []string{failing.URL, healthy.URL}},
+	}}
+	service.Balancer = &RoundRobinBalancer{}
+	service.OutlierDetection = &OutlierPolicy{
+		ConsecutiveFailures: 2,
+		BaseEjection:        time.Minute,
+	}
+
+	// Start from a clean record of the instances.
+	outlierDetectors.Lock()
+	delete(outlierDetectors.detectors, service.GetIdentity())
+	outlierDetectors.Unlock()
+
+	statuses := []string{}
+	for i := 0; i < 8; i++ {
+		if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+			t.Fatalf("TestService_Call_outlierDetection: %s", err)
+		}
+
+		resp, err := service.Call()
+		if err != nil {
+			t.Fatalf("TestService_Call_outlierDetection: %s", err)
+		}
+		resp.Body.Close()
+
+		statuses = append(statuses, resp.Status[:3])
+	}
+
+	expected := "500,200,500,200,200,200,200,200"
+	if strings.Join(statuses, ",") != expected {
+		t.Errorf("TestService_Call_outlierDetection: expected %v got %v", expected, strings.Join(statuses, ","))
+	}
+
+	failingUrl, _ := url.Parse(failing.URL)
+	ejected := service.GetEjectedHosts()
+	if len(ejected) != 1 || ejected[0] != failingUrl.Host {
+		t.Errorf("TestService_Call_outlierDetection: expected %v ejected got %v", failingUrl.Host, ejected)
+	}
+}
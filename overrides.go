@@ -0,0 +1,199 @@
+package microservicetransport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// OverridesEnv - Name of the environment variable holding local overrides.
+	OverridesEnv = "SOA_OVERRIDES"
+
+	// OverridesFile - Name of the dotfile holding local overrides, looked for
+	// in the working directory and then the home directory.
+	OverridesFile = ".soa-overrides"
+)
+
+// Overrides - Routes services to fixed URLs ahead of any other resolution,
+// e.g. to reach services running on localhost during development.
+//
+// Overrides are written as key=url, separated by commas or new lines, where
+// the key is [namespace/]name[/vN][@branch]:
+//
+//	SOA_OVERRIDES=orders=http://localhost:8081,services/payments/v2@feature-x=http://localhost:8082
+//
+// Parts left out of a key match any value, and the first matching override wins.
+type Overrides struct {
+	Entries []StaticEntry // Overrides, in order of precedence, each routed to its first URL.
+	Logger  *log.Logger   // Logger told which overrides are applied, the standard logger if nil.
+
+	logged sync.Map // Overrides already logged, so each is logged once.
+}
+
+// overrideVersionPattern - Pattern of the version part of an override key.
+var overrideVersionPattern = regexp.MustCompile(`^v([0-9]+)$`)
+
+// defaultOverrides - Overrides loaded from the environment, on first use.
+var defaultOverrides struct {
+	once      sync.Once
+	overrides *Overrides
+	err       error
+}
+
+// DefaultOverrides - Get the overrides from the SOA_OVERRIDES environment
+// variable and the .soa-overrides dotfile, loaded once on first use.
+func DefaultOverrides() (*Overrides, error) {
+	defaultOverrides.once.Do(func() {
+		defaultOverrides.overrides, defaultOverrides.err = LoadOverrides()
+	})
+
+	return defaultOverrides.overrides, defaultOverrides.err
+}
+
+// LoadOverrides - Load the overrides from the SOA_OVERRIDES environment
+// variable, followed by those in the first .soa-overrides dotfile found.
+func LoadOverrides() (*Overrides, error) {
+	overrides, err := ParseOverrides(strings.NewReader(os.Getenv(OverridesEnv)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", OverridesEnv, err)
+	}
+
+	paths := []string{OverridesFile}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, OverridesFile))
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot open overrides: %w", err)
+		}
+		defer f.Close()
+
+		fileOverrides, err := ParseOverrides(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid overrides in %s: %w", path, err)
+		}
+		overrides.Entries = append(overrides.Entries, fileOverrides.Entries...)
+
+		break
+	}
+
+	return overrides, nil
+}
+
+// ParseOverrides - Parse overrides separated by commas or new lines. Blank
+// lines and lines starting with # are ignored.
+func ParseOverrides(r io.Reader) (*Overrides, error) {
+	overrides := &Overrides{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		for _, override := range strings.Split(line, ",") {
+			if override = strings.TrimSpace(override); override == "" {
+				continue
+			}
+
+			entry, err := parseOverride(override)
+			if err != nil {
+				return nil, err
+			}
+			overrides.Entries = append(overrides.Entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read overrides: %w", err)
+	}
+
+	return overrides, nil
+}
+
+// parseOverride - Parse a single key=url override.
+func parseOverride(override string) (StaticEntry, error) {
+	entry := StaticEntry{}
+
+	parts := strings.SplitN(override, "=", 2)
+	if len(parts) != 2 {
+		return entry, fmt.Errorf("invalid override %q: expected key=url", override)
+	}
+	key, rawUrl := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+	if _, err := parseBaseUrl(rawUrl); err != nil {
+		return entry, fmt.Errorf("invalid override %q: %w", override, err)
+	}
+	entry.URLs = []string{rawUrl}
+
+	if i := strings.LastIndex(key, "@"); i >= 0 {
+		key, entry.Branch = key[:i], key[i+1:]
+	}
+
+	segments := strings.Split(key, "/")
+	if match := overrideVersionPattern.FindStringSubmatch(segments[len(segments)-1]); match != nil && len(segments) > 1 {
+		entry.Version, _ = strconv.Atoi(match[1])
+		segments = segments[:len(segments)-1]
+	}
+
+	switch len(segments) {
+	case 1:
+		entry.Name = segments[0]
+	case 2:
+		entry.Namespace, entry.Name = segments[0], segments[1]
+	default:
+		return entry, fmt.Errorf("invalid override %q: expected [namespace/]name[/vN][@branch]", override)
+	}
+
+	if entry.Name == "" {
+		return entry, fmt.Errorf("invalid override %q: missing name", override)
+	}
+
+	return entry, nil
+}
+
+// lookup - Get the URL of the first override matching the identity, logging
+// the override the first time it is applied.
+func (o *Overrides) lookup(identity Identity) (*url.URL, bool) {
+	if o == nil {
+		return nil, false
+	}
+
+	for _, entry := range o.Entries {
+		if !entry.matches(identity) || len(entry.URLs) == 0 {
+			continue
+		}
+
+		u, err := parseBaseUrl(entry.URLs[0])
+		if err != nil {
+			continue
+		}
+
+		if _, logged := o.logged.LoadOrStore(identity.String()+" "+u.String(), true); !logged {
+			logger := o.Logger
+			if logger == nil {
+				logger = log.Default()
+			}
+			logger.Printf("microservicetransport: routing %s to override %s", identity, u)
+		}
+
+		return u, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,224 @@
+package microservicetransport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseOverrides(t *testing.T) {
+	tt := []struct {
+		name            string
+		overrides       string
+		expectedEntries []StaticEntry
+		expectedErr     bool
+	}{
+		{
+			name:      "Names",
+			overrides: "orders=http://localhost:8081, payments=http://localhost:8082",
+			expectedEntries: []StaticEntry{
+				{Name: "orders", URLs: []string{"http://localhost:8081"}},
+				{Name: "payments", URLs: []string{"http://localhost:8082"}},
+			},
+		},
+		{
+			name:      "Qualified keys",
+			overrides: "# Local services\n\nservices/orders/v2@feature-x=http://localhost:8081\norders/v1=http://localhost:8082\nv2=http://localhost:8083",
+			expectedEntries: []StaticEntry{
+				{Name: "orders", Namespace: "services", Version: 2, Branch: "feature-x", URLs: []string{"http://localhost:8081"}},
+				{Name: "orders", Version: 1, URLs: []string{"http://localhost:8082"}},
+				{Name: "v2", URLs: []string{"http://localhost:8083"}},
+			},
+		},
+		{
+			name:        "Missing url",
+			overrides:   "orders",
+			expectedErr: true,
+		},
+		{
+			name:        "Relative url",
+			overrides:   "orders=localhost:8081",
+			expectedErr: true,
+		},
+		{
+			name:        "Too many segments",
+			overrides:   "a/b/c=http://localhost:8081",
+			expectedErr: true,
+		},
+		{
+			name:        "Missing name",
+			overrides:   "@master=http://localhost:8081",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			overrides, err := ParseOverrides(strings.NewReader(tc.overrides))
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("TestParseOverrides: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+			if tc.expectedErr {
+				return
+			}
+
+			if len(overrides.Entries) != len(tc.expectedEntries) {
+				t.Fatalf("TestParseOverrides: %s: expected %+v got %+v", tc.name, tc.expectedEntries, overrides.Entries)
+			}
+			for i, entry := range overrides.Entries {
+				expected := tc.expectedEntries[i]
+				if entry.Name != expected.Name || entry.Namespace != expected.Namespace || entry.Version != expected.Version ||
+					entry.Branch != expected.Branch || entry.URLs[0] != expected.URLs[0] {
+					t.Errorf("TestParseOverrides: %s: expected %+v got %+v", tc.name, expected, entry)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	home, err := ioutil.TempDir("", "home")
+	if err != nil {
+		t.Fatalf("TestLoadOverrides: %s", err)
+	}
+	defer os.RemoveAll(home)
+
+	err = ioutil.WriteFile(filepath.Join(home, OverridesFile), []byte("orders=http://localhost:9091\npayments=http://localhost:9092\n"), 0644)
+	if err != nil {
+		t.Fatalf("TestLoadOverrides: %s", err)
+	}
+
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	defer os.Unsetenv(OverridesEnv)
+	os.Setenv(OverridesEnv, "orders=http://localhost:8081")
+
+	overrides, err := LoadOverrides()
+	if err != nil {
+		t.Fatalf("TestLoadOverrides: %s", err)
+	}
+
+	// The environment takes precedence over the dotfile.
+	u, ok := overrides.lookup(Identity{Name: "orders"})
+	if !ok || u.String() != "http://localhost:8081" {
+		t.Errorf("TestLoadOverrides: expected %v got %v", "http://localhost:8081", u)
+	}
+
+	u, ok = overrides.lookup(Identity{Name: "payments"})
+	if !ok || u.String() != "http://localhost:9092" {
+		t.Errorf("TestLoadOverrides: expected %v got %v", "http://localhost:9092", u)
+	}
+
+	os.Setenv(OverridesEnv, "orders")
+	if _, err := LoadOverrides(); err == nil {
+		t.Errorf("TestLoadOverrides: expected an error for an invalid override")
+	}
+}
+
+func TestService_Dial_overrides(t *testing.T) {
+	logs := &bytes.Buffer{}
+	overrides, _ := ParseOverrides(strings.NewReader("orders/v2@feature-x=http://localhost:8082/v2,aggregators/myaggregator=http://localhost:8083"))
+	overrides.Logger = log.New(logs, "", 0)
+
+	tt := []struct {
+		name        string
+		service     *Service
+		expectedUrl string
+	}{
+		{
+			name:        "Overridden",
+			service:     &Service{Name: "orders", Namespace: "services", Branch: "feature-x", Environment: "staging", Version: 2},
+			expectedUrl: "http://localhost:8082/v2/things",
+		},
+		{
+			name:        "Other branch",
+			service:     &Service{Name: "orders", Namespace: "services", Branch: "master", Environment: "staging", Version: 2},
+			expectedUrl: "http://orders-master-staging.orders-2/things",
+		},
+		{
+			name:        "Aggregator by its own name",
+			service:     &Service{Name: "myaggregator", Namespace: "aggregators", Branch: "master", Environment: "staging"},
+			expectedUrl: "http://localhost:8083/things",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.service.Overrides = overrides
+
+			for i := 0; i < 2; i++ {
+				if err := tc.service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+					t.Fatalf("TestService_Dial_overrides: %s: %s", tc.name, err)
+				}
+			}
+
+			if tc.service.CurrentRequest.URL.String() != tc.expectedUrl {
+				t.Errorf("TestService_Dial_overrides: %s: expected %v got %v", tc.name, tc.expectedUrl, tc.service.CurrentRequest.URL.String())
+			}
+		})
+	}
+
+	// Each override is logged the first time it is applied.
+	expectedLogs := "microservicetransport: routing services/orders@feature-x-staging/v2 to override http://localhost:8082/v2\n" +
+		"microservicetransport: routing aggregators/myaggregator@master-staging/v0 to override http://localhost:8083\n"
+	if logs.String() != expectedLogs {
+		t.Errorf("TestService_Dial_overrides: expected logs %q got %q", expectedLogs, logs.String())
+	}
+}
+
+func TestCloudService_Dial_overrides(t *testing.T) {
+	// Start a HTTP server to act as the service running locally.
+	var headers http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+	}))
+	defer ts.Close()
+
+	overrides, _ := ParseOverrides(strings.NewReader("orders=" + ts.URL))
+	overrides.Logger = log.New(ioutil.Discard, "", 0)
+
+	// No gateway is configured, so the dial only succeeds without it.
+	service := NewCloudService(DefaultHttpClient(), "master", "qa2", "services", "orders", &AuthCredentials{})
+	service.Version = 2
+	service.Overrides = overrides
+
+	err := service.Dial(&Request{
+		Method:   http.MethodGet,
+		Resource: "things",
+		Headers:  map[string]string{"X-Test": "test"},
+	})
+	if err != nil {
+		t.Fatalf("TestCloudService_Dial_overrides: %s", err)
+	}
+
+	expectedUrl := ts.URL + "/things"
+	if service.CurrentRequest.URL.String() != expectedUrl {
+		t.Errorf("TestCloudService_Dial_overrides: expected %v got %v", expectedUrl, service.CurrentRequest.URL.String())
+	}
+
+	if service.CurrentRequest.Header.Get("x-service-version") != "2" || service.CurrentRequest.Header.Get("X-Test") != "test" {
+		t.Errorf("TestCloudService_Dial_overrides: expected version and request headers got %v", service.CurrentRequest.Header)
+	}
+	if service.CurrentRequest.Header.Get("Authorization") != "" {
+		t.Errorf("TestCloudService_Dial_overrides: expected no auth header got %v", service.CurrentRequest.Header.Get("Authorization"))
+	}
+
+	resp, err := service.Call()
+	if err != nil {
+		t.Fatalf("TestCloudService_Dial_overrides: %s", err)
+	}
+	resp.Body.Close()
+
+	if headers.Get("x-service-version") != "2" || headers.Get("X-Test") != "test" {
+		t.Errorf("TestCloudService_Dial_overrides: expected version and request headers got %v", headers)
+	}
+	if headers.Get("Authorization") != "" {
+		t.Errorf("TestCloudService_Dial_overrides: expected no auth header got %v", headers.Get("Authorization"))
+	}
+}
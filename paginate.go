@@ -0,0 +1,149 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"github.com/LUSHDigital/microservice-core-golang/pagination"
+)
+
+// PageIterator - Walks the pages of a paginated service resource, following
+// next_page until there are no pages left.
+//
+//	it := NewPageIterator(service, &Request{Method: http.MethodGet, Resource: "things"}, "things")
+//	for it.Next(ctx, &things) {
+//	    ...
+//	}
+//	if err := it.Err(); err != nil {
+//	    ...
+//	}
+type PageIterator struct {
+	Transport Transport // Transport to fetch pages with.
+	Request   *Request  // Request for the resource, used as a template for each page.
+	Key       string    // Key of the items in the response data.
+	PerPage   int       // Number of items to request per page, the service default if 0.
+	MaxPages  int       // Most pages to fetch, unlimited if 0.
+	MaxItems  int       // Most items to yield, unlimited if 0.
+
+	next       int                  // Number of the next page to fetch, the first page if 0.
+	pages      int                  // Number of pages fetched.
+	items      int                  // Number of items yielded.
+	pagination *pagination.Response // Pagination data of the last page fetched.
+	done       bool
+	err        error
+}
+
+// NewPageIterator - Prepare an iterator over the pages of a resource, starting
+// from the first page.
+func NewPageIterator(t Transport, request *Request, key string) *PageIterator {
+	return &PageIterator{
+		Transport: t,
+		Request:   request,
+		Key:       key,
+		next:      1,
+	}
+}
+
+// Next - Fetch the next page and decode its items into dst, which must be a
+// pointer to a slice. Returns false once there are no pages left, a limit is
+// reached, the context is done or an error occurs. A next_page which does not
+// move forward stops the iteration with an error after the page it is on.
+func (it *PageIterator) Next(ctx context.Context, dst interface{}) bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+
+	if it.MaxPages > 0 && it.pages >= it.MaxPages {
+		it.done = true
+		return false
+	}
+
+	items := reflect.ValueOf(dst)
+	if items.Kind() != reflect.Ptr || items.Elem().Kind() != reflect.Slice {
+		it.err = errors.New("cannot iterate pages: destination must be a pointer to a slice")
+		return false
+	}
+	items = items.Elem()
+
+	page := it.nextPage()
+	if err := it.Transport.DialContext(ctx, it.pageRequest()); err != nil {
+		it.err = err
+		return false
+	}
+
+	items.Set(reflect.Zero(items.Type()))
+	serviceResponse, err := CallAndDecodePaginated(ctx, it.Transport, it.Key, dst)
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.pages++
+	it.pagination = serviceResponse.Pagination
+
+	// Trim the page to the item limit.
+	if it.MaxItems > 0 {
+		if remaining := it.MaxItems - it.items; items.Len() >= remaining {
+			items.Set(items.Slice(0, remaining))
+			it.done = true
+		}
+	}
+	it.items += items.Len()
+
+	switch {
+	case it.pagination == nil || it.pagination.NextPage == nil:
+		it.done = true
+	case *it.pagination.NextPage <= page:
+		it.err = fmt.Errorf("cannot iterate pages: next page %d of page %d does not move forward", *it.pagination.NextPage, page)
+	default:
+		it.next = *it.pagination.NextPage
+	}
+
+	return true
+}
+
+// Err - Get the error which stopped the iteration, if any.
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+// Pagination - Get the pagination data of the last page fetched.
+func (it *PageIterator) Pagination() *pagination.Response {
+	return it.pagination
+}
+
+// nextPage - Get the number of the next page to fetch.
+func (it *PageIterator) nextPage() int {
+	if it.next == 0 {
+		return 1
+	}
+
+	return it.next
+}
+
+// pageRequest - Build the request for the next page.
+func (it *PageIterator) pageRequest() *Request {
+	query := url.Values{}
+	for key, values := range it.Request.Query {
+		query[key] = append([]string(nil), values...)
+	}
+
+	query.Set("page", strconv.Itoa(it.nextPage()))
+	if it.PerPage > 0 {
+		query.Set("per_page", strconv.Itoa(it.PerPage))
+	}
+
+	request := *it.Request
+	request.Query = query
+
+	return &request
+}
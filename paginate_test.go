@@ -0,0 +1,160 @@
+package microservicetransport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/LUSHDigital/microservice-core-golang/format"
+	"github.com/LUSHDigital/microservice-core-golang/pagination"
+	"github.com/LUSHDigital/microservice-core-golang/response"
+)
+
+func TestPageIterator(t *testing.T) {
+	// Start a HTTP server serving 5 things, 2 per page by default.
+	things := []thing{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if perPage == 0 {
+			perPage = 2
+		}
+
+		paginator, err := pagination.NewPaginator(perPage, page, len(things))
+		if err != nil {
+			t.Fatalf("TestPageIterator: %s", err)
+		}
+
+		end := paginator.GetOffset() + perPage
+		if end > len(things) {
+			end = len(things)
+		}
+
+		resp := response.NewPaginated(paginator, http.StatusOK, "", &response.Data{
+			Type:    "things",
+			Content: things[paginator.GetOffset():end],
+		})
+		format.JSONResponseFormatter(w, resp)
+	}))
+	defer ts.Close()
+
+	tt := []struct {
+		name          string
+		perPage       int
+		maxPages      int
+		maxItems      int
+		expectedPages [][]int
+	}{
+		{
+			name:          "All pages",
+			expectedPages: [][]int{{1, 2}, {3, 4}, {5}},
+		},
+		{
+			name:          "Per page",
+			perPage:       3,
+			expectedPages: [][]int{{1, 2, 3}, {4, 5}},
+		},
+		{
+			name:          "Page limit",
+			maxPages:      2,
+			expectedPages: [][]int{{1, 2}, {3, 4}},
+		},
+		{
+			name:          "Item limit",
+			maxItems:      3,
+			expectedPages: [][]int{{1, 2}, {3}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			service := NewService(redirectClient(ts), "master", "staging", "services", "myservice")
+
+			it := NewPageIterator(service, &Request{Method: http.MethodGet, Resource: "things"}, "things")
+			it.PerPage = tc.perPage
+			it.MaxPages = tc.maxPages
+			it.MaxItems = tc.maxItems
+
+			var (
+				page  []thing
+				pages [][]int
+			)
+			for it.Next(context.Background(), &page) {
+				ids := []int{}
+				for _, item := range page {
+					ids = append(ids, item.ID)
+				}
+				pages = append(pages, ids)
+			}
+
+			if err := it.Err(); err != nil {
+				t.Fatalf("TestPageIterator: %s: %s", tc.name, err)
+			}
+
+			if !reflect.DeepEqual(pages, tc.expectedPages) {
+				t.Errorf("TestPageIterator: %s: expected %v got %v", tc.name, tc.expectedPages, pages)
+			}
+		})
+	}
+}
+
+func TestPageIterator_cancelled(t *testing.T) {
+	service := NewService(DefaultHttpClient(), "master", "staging", "services", "myservice")
+	it := NewPageIterator(service, &Request{Method: http.MethodGet, Resource: "things"}, "things")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var page []thing
+	if it.Next(ctx, &page) {
+		t.Fatalf("TestPageIterator_cancelled: expected no pages")
+	}
+
+	if it.Err() != context.Canceled {
+		t.Errorf("TestPageIterator_cancelled: expected %v got %v", context.Canceled, it.Err())
+	}
+}
+
+func TestPageIterator_literal(t *testing.T) {
+	// Start a HTTP server which always points back at the page requested,
+	// recording the pages asked for.
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("page"))
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		resp := response.NewPaginated(&pagination.Paginator{}, http.StatusOK, "", &response.Data{
+			Type:    "things",
+			Content: []thing{{ID: page}},
+		})
+		resp.Pagination = &pagination.Response{CurrentPage: page, NextPage: &page}
+		format.JSONResponseFormatter(w, resp)
+	}))
+	defer ts.Close()
+
+	service := NewService(redirectClient(ts), "master", "staging", "services", "myservice")
+	it := &PageIterator{Transport: service, Request: &Request{Method: http.MethodGet, Resource: "things"}, Key: "things"}
+
+	var (
+		page  []thing
+		pages int
+	)
+	for it.Next(context.Background(), &page) {
+		pages++
+	}
+
+	if pages != 1 {
+		t.Errorf("TestPageIterator_literal: expected 1 page got %d", pages)
+	}
+
+	if !reflect.DeepEqual(requested, []string{"1"}) {
+		t.Errorf("TestPageIterator_literal: expected page 1 to be requested got %v", requested)
+	}
+
+	if it.Err() == nil {
+		t.Errorf("TestPageIterator_literal: expected an error for a next page which does not move forward")
+	}
+}
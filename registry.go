@@ -0,0 +1,283 @@
+package microservicetransport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/config"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ModeLocal - Dependency mode for services reached directly in the cluster.
+	ModeLocal = "local"
+
+	// ModeCloud - Dependency mode for services reached through the API gateway.
+	ModeCloud = "cloud"
+)
+
+// Manifest - Declares the services a service depends on, as JSON or the
+// equivalent YAML.
+//
+//	{
+//	    "branch": "master",
+//	    "environment": "staging",
+//	    "dependencies": [
+//	        {"name": "orders", "namespace": "services", "version": 1, "timeout": "2s"},
+//	        {"name": "payments", "namespace": "services", "mode": "cloud", "protocol": "https",
+//	         "credentials": {"email_env": "PAYMENTS_EMAIL", "password_env": "PAYMENTS_PASSWORD"}}
+//	    ]
+//	}
+type Manifest struct {
+	Branch       string       `json:"branch" yaml:"branch"`             // VCS branch of every dependency, unless it sets its own.
+	Environment  string       `json:"environment" yaml:"environment"`   // CI environment of every dependency, unless it sets its own.
+	Dependencies []Dependency `json:"dependencies" yaml:"dependencies"` // Services depended on.
+}
+
+// Dependency - Declares a service depended on.
+type Dependency struct {
+	Name        string          `json:"name" yaml:"name"`                                   // Name of the service, which it is looked up by.
+	Namespace   string          `json:"namespace" yaml:"namespace"`                         // Namespace of the service.
+	Version     int             `json:"version,omitempty" yaml:"version,omitempty"`         // Major API version of the service.
+	Branch      string          `json:"branch,omitempty" yaml:"branch,omitempty"`           // VCS branch of the service, the manifest branch if empty.
+	Environment string          `json:"environment,omitempty" yaml:"environment,omitempty"` // CI environment of the service, the manifest environment if empty.
+	Protocol    string          `json:"protocol,omitempty" yaml:"protocol,omitempty"`       // Transfer protocol to use, http if empty.
+	Mode        string          `json:"mode,omitempty" yaml:"mode,omitempty"`               // ModeLocal or ModeCloud, ModeLocal if empty.
+	Timeout     string          `json:"timeout,omitempty" yaml:"timeout,omitempty"`         // Timeout for calls, e.g. "2s", that of DefaultHttpClient if empty.
+	Credentials *CredentialsRef `json:"credentials,omitempty" yaml:"credentials,omitempty"` // Credentials for cloud services.
+}
+
+// CredentialsRef - Refers to the environment variables holding the
+// credentials for a cloud service, so they are kept out of the manifest.
+type CredentialsRef struct {
+	EmailEnv    string `json:"email_env" yaml:"email_env"`       // Name of the variable holding the email.
+	PasswordEnv string `json:"password_env" yaml:"password_env"` // Name of the variable holding the password.
+}
+
+// Registry - Hands out transports for the services declared in a manifest.
+type Registry struct {
+	dependencies map[string]registryEntry
+}
+
+// registryEntry - A validated dependency, ready to build transports for.
+type registryEntry struct {
+	dependency  Dependency
+	client      *http.Client
+	credentials *AuthCredentials
+}
+
+// LoadRegistry - Load a registry from a JSON manifest.
+func LoadRegistry(r io.Reader) (*Registry, error) {
+	manifest := Manifest{}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("cannot decode manifest: %w", err)
+	}
+
+	return NewRegistry(manifest)
+}
+
+// LoadRegistryYAML - Load a registry from a YAML manifest.
+func LoadRegistryYAML(r io.Reader) (*Registry, error) {
+	manifest := Manifest{}
+	decoder := yaml.NewDecoder(r)
+	decoder.SetStrict(true)
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("cannot decode manifest: %w", err)
+	}
+
+	return NewRegistry(manifest)
+}
+
+// LoadRegistryFile - Load a registry from a manifest file, which is read as
+// YAML if it has a .yaml or .yml extension and as JSON otherwise.
+func LoadRegistryFile(path string) (*Registry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open manifest: %w", err)
+	}
+	defer f.Close()
+
+	if isYAMLFile(path) {
+		return LoadRegistryYAML(f)
+	}
+
+	return LoadRegistry(f)
+}
+
+// NewRegistry - Prepare a registry for the dependencies in a manifest. Every
+// dependency is validated up front, and all of the problems found are
+// returned together.
+func NewRegistry(manifest Manifest) (*Registry, error) {
+	registry := &Registry{dependencies: make(map[string]registryEntry)}
+
+	problems := []string{}
+	declared := make(map[string]bool)
+	for i, dependency := range manifest.Dependencies {
+		if dependency.Branch == "" {
+			dependency.Branch = manifest.Branch
+		}
+		if dependency.Environment == "" {
+			dependency.Environment = manifest.Environment
+		}
+		if dependency.Mode == "" {
+			dependency.Mode = ModeLocal
+		}
+
+		label := dependency.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+
+		entry, errs := newRegistryEntry(dependency)
+		if dependency.Name != "" && declared[dependency.Name] {
+			errs = append(errs, "declared more than once")
+		}
+		declared[dependency.Name] = true
+
+		for _, err := range errs {
+			problems = append(problems, fmt.Sprintf("%s: %s", label, err))
+		}
+
+		if len(errs) == 0 {
+			registry.dependencies[dependency.Name] = entry
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid manifest: %s", strings.Join(problems, "; "))
+	}
+
+	return registry, nil
+}
+
+// newRegistryEntry - Validate a dependency, returning every problem with it.
+func newRegistryEntry(dependency Dependency) (registryEntry, []string) {
+	entry := registryEntry{
+		dependency: dependency,
+		client:     DefaultHttpClient(),
+	}
+
+	problems := []string{}
+	if dependency.Name == "" {
+		problems = append(problems, "missing name")
+	}
+	if dependency.Namespace == "" {
+		problems = append(problems, "missing namespace")
+	}
+	if dependency.Branch == "" {
+		problems = append(problems, "missing branch")
+	}
+	if dependency.Environment == "" {
+		problems = append(problems, "missing environment")
+	}
+	if dependency.Version < 0 {
+		problems = append(problems, fmt.Sprintf("invalid version %d", dependency.Version))
+	}
+
+	switch dependency.Protocol {
+	case "", config.ProtocolHTTP, config.ProtocolHTTPS:
+	default:
+		problems = append(problems, fmt.Sprintf("invalid protocol %q", dependency.Protocol))
+	}
+
+	if dependency.Timeout != "" {
+		timeout, err := time.ParseDuration(dependency.Timeout)
+		if err != nil || timeout <= 0 {
+			problems = append(problems, fmt.Sprintf("invalid timeout %q", dependency.Timeout))
+		}
+		entry.client.Timeout = timeout
+	}
+
+	switch dependency.Mode {
+	case ModeLocal:
+		if dependency.Credentials != nil {
+			problems = append(problems, "credentials are only used in cloud mode")
+		}
+
+	case ModeCloud:
+		if dependency.Credentials == nil {
+			problems = append(problems, "missing credentials")
+			break
+		}
+
+		credentials, err := dependency.Credentials.resolve()
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		entry.credentials = credentials
+
+	default:
+		problems = append(problems, fmt.Sprintf("invalid mode %q", dependency.Mode))
+	}
+
+	return entry, problems
+}
+
+// resolve - Read the credentials from the environment.
+func (c *CredentialsRef) resolve() (*AuthCredentials, error) {
+	if c.EmailEnv == "" || c.PasswordEnv == "" {
+		return nil, fmt.Errorf("credentials must name email_env and password_env")
+	}
+
+	credentials := &AuthCredentials{
+		Email:    os.Getenv(c.EmailEnv),
+		Password: os.Getenv(c.PasswordEnv),
+	}
+
+	missing := []string{}
+	if credentials.Email == "" {
+		missing = append(missing, c.EmailEnv)
+	}
+	if credentials.Password == "" {
+		missing = append(missing, c.PasswordEnv)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("credentials not set in %s", strings.Join(missing, ", "))
+	}
+
+	return credentials, nil
+}
+
+// Get - Get a new transport for a dependency. Each call returns a transport
+// of its own, as transports hold the state of their current request, while
+// the HTTP client and token cache are shared.
+func (r *Registry) Get(name string) (Transport, error) {
+	entry, ok := r.dependencies[name]
+	if !ok {
+		return nil, fmt.Errorf("no dependency named %s in the registry", name)
+	}
+
+	dependency := entry.dependency
+	if dependency.Mode == ModeCloud {
+		cloudService := NewCloudService(entry.client, dependency.Branch, dependency.Environment, dependency.Namespace, dependency.Name, entry.credentials)
+		cloudService.Version = dependency.Version
+		cloudService.Protocol = dependency.Protocol
+
+		return cloudService, nil
+	}
+
+	service := NewService(entry.client, dependency.Branch, dependency.Environment, dependency.Namespace, dependency.Name)
+	service.Version = dependency.Version
+	service.Protocol = dependency.Protocol
+
+	return service, nil
+}
+
+// Names - Get the names of the dependencies in the registry.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.dependencies))
+	for name := range r.dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
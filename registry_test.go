@@ -0,0 +1,244 @@
+package microservicetransport
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadRegistry(t *testing.T) {
+	os.Setenv("TEST_REGISTRY_EMAIL", "test@test.com")
+	os.Setenv("TEST_REGISTRY_PASSWORD", "1234")
+	defer os.Unsetenv("TEST_REGISTRY_EMAIL")
+	defer os.Unsetenv("TEST_REGISTRY_PASSWORD")
+
+	tt := []struct {
+		name        string
+		manifest    string
+		expectedErr string
+	}{
+		{
+			name: "Valid",
+			manifest: `{"branch": "master", "environment": "staging", "dependencies": [
+				{"name": "orders", "namespace": "services", "version": 1, "timeout": "2s"},
+				{"name": "payments", "namespace": "services", "mode": "cloud", "protocol": "https",
+				 "credentials": {"email_env": "TEST_REGISTRY_EMAIL", "password_env": "TEST_REGISTRY_PASSWORD"}}
+			]}`,
+		},
+		{
+			name:        "Not JSON",
+			manifest:    `dependencies: []`,
+			expectedErr: "cannot decode manifest",
+		},
+		{
+			name:        "Unknown field",
+			manifest:    `{"dependencies": [{"name": "orders", "namespace": "services", "timeuot": "2s"}]}`,
+			expectedErr: `unknown field "timeuot"`,
+		},
+		{
+			name: "Invalid entries",
+			manifest: `{"environment": "staging", "dependencies": [
+				{"name": "orders", "namespace": "services", "branch": "master", "protocol": "ftp", "timeout": "soon"},
+				{"namespace": "services", "branch": "master"},
+				{"name": "payments", "namespace": "services", "branch": "master", "mode": "cloud",
+				 "credentials": {"email_env": "TEST_REGISTRY_EMAIL", "password_env": "TEST_REGISTRY_MISSING"}},
+				{"name": "orders", "namespace": "services", "mode": "remote"}
+			]}`,
+			expectedErr: `invalid manifest: orders: invalid protocol "ftp"; orders: invalid timeout "soon"; ` +
+				`#2: missing name; payments: credentials not set in TEST_REGISTRY_MISSING; ` +
+				`orders: missing branch; orders: invalid mode "remote"; orders: declared more than once`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadRegistry(strings.NewReader(tc.manifest))
+			if tc.expectedErr == "" && err != nil {
+				t.Errorf("TestLoadRegistry: %s: %s", tc.name, err)
+			}
+
+			if tc.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErr)) {
+				t.Errorf("TestLoadRegistry: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadRegistryYAML(t *testing.T) {
+	os.Setenv("TEST_REGISTRY_EMAIL", "test@test.com")
+	os.Setenv("TEST_REGISTRY_PASSWORD", "1234")
+	defer os.Unsetenv("TEST_REGISTRY_EMAIL")
+	defer os.Unsetenv("TEST_REGISTRY_PASSWORD")
+
+	tt := []struct {
+		name        string
+		manifest    string
+		expectedErr string
+	}{
+		{
+			name: "Valid",
+			manifest: `branch: master
+environment: staging
+dependencies:
+  - name: orders
+    namespace: services
+    version: 1
+    timeout: 2s
+  - name: payments
+    namespace: services
+    mode: cloud
+    protocol: https
+    credentials:
+      email_env: TEST_REGISTRY_EMAIL
+      password_env: TEST_REGISTRY_PASSWORD
+`,
+		},
+		{
+			name:        "Not YAML",
+			manifest:    `dependencies: [`,
+			expectedErr: "cannot decode manifest",
+		},
+		{
+			name: "Unknown field",
+			manifest: `dependencies:
+  - name: orders
+    namespace: services
+    timeuot: 2s
+`,
+			expectedErr: "field timeuot not found",
+		},
+		{
+			name: "Invalid entries",
+			manifest: `branch: master
+dependencies:
+  - name: orders
+    namespace: services
+`,
+			expectedErr: "invalid manifest: orders: missing environment",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadRegistryYAML(strings.NewReader(tc.manifest))
+			if tc.expectedErr == "" && err != nil {
+				t.Errorf("TestLoadRegistryYAML: %s: %s", tc.name, err)
+			}
+
+			if tc.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedErr)) {
+				t.Errorf("TestLoadRegistryYAML: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadRegistryFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tt := []struct {
+		name     string
+		file     string
+		manifest string
+	}{
+		{
+			name:     "JSON",
+			file:     "manifest.json",
+			manifest: `{"branch": "master", "environment": "staging", "dependencies": [{"name": "orders", "namespace": "services"}]}`,
+		},
+		{
+			name:     "YAML",
+			file:     "manifest.yaml",
+			manifest: "branch: master\nenvironment: staging\ndependencies:\n  - name: orders\n    namespace: services\n",
+		},
+		{
+			name:     "YML",
+			file:     "manifest.yml",
+			manifest: "branch: master\nenvironment: staging\ndependencies:\n  - {name: orders, namespace: services}\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.file)
+			if err := os.WriteFile(path, []byte(tc.manifest), 0600); err != nil {
+				t.Fatalf("TestLoadRegistryFile: %s: %s", tc.name, err)
+			}
+
+			registry, err := LoadRegistryFile(path)
+			if err != nil {
+				t.Fatalf("TestLoadRegistryFile: %s: %s", tc.name, err)
+			}
+
+			if _, err := registry.Get("orders"); err != nil {
+				t.Errorf("TestLoadRegistryFile: %s: %s", tc.name, err)
+			}
+		})
+	}
+}
+
+func TestRegistry_Get(t *testing.T) {
+	os.Setenv("TEST_REGISTRY_EMAIL", "test@test.com")
+	os.Setenv("TEST_REGISTRY_PASSWORD", "1234")
+	defer os.Unsetenv("TEST_REGISTRY_EMAIL")
+	defer os.Unsetenv("TEST_REGISTRY_PASSWORD")
+
+	registry, err := LoadRegistry(strings.NewReader(`{"branch": "master", "environment": "staging", "dependencies": [
+		{"name": "orders", "namespace": "services", "version": 2, "timeout": "2s", "protocol": "https"},
+		{"name": "payments", "namespace": "services", "environment": "prod", "mode": "cloud",
+		 "credentials": {"email_env": "TEST_REGISTRY_EMAIL", "password_env": "TEST_REGISTRY_PASSWORD"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("TestRegistry_Get: %s", err)
+	}
+
+	if names := strings.Join(registry.Names(), ","); names != "orders,payments" {
+		t.Errorf("TestRegistry_Get: expected %v got %v", "orders,payments", names)
+	}
+
+	transport, err := registry.Get("orders")
+	if err != nil {
+		t.Fatalf("TestRegistry_Get: %s", err)
+	}
+
+	service, ok := transport.(*Service)
+	if !ok {
+		t.Fatalf("TestRegistry_Get: expected a *Service got %T", transport)
+	}
+	if service.Version != 2 || service.Client.Timeout != 2*time.Second || service.Branch != "master" {
+		t.Errorf("TestRegistry_Get: unexpected service %+v", service)
+	}
+
+	// The protocol of the dependency applies to requests which do not set one.
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err != nil {
+		t.Fatalf("TestRegistry_Get: %s", err)
+	}
+	expectedUrl := "https://orders-master-staging.orders-2/things"
+	if service.CurrentRequest.URL.String() != expectedUrl {
+		t.Errorf("TestRegistry_Get: expected %v got %v", expectedUrl, service.CurrentRequest.URL.String())
+	}
+
+	// Each transport has its own state.
+	if other, _ := registry.Get("orders"); other == transport {
+		t.Errorf("TestRegistry_Get: expected a new transport")
+	}
+
+	transport, err = registry.Get("payments")
+	if err != nil {
+		t.Fatalf("TestRegistry_Get: %s", err)
+	}
+
+	cloudService, ok := transport.(*CloudService)
+	if !ok {
+		t.Fatalf("TestRegistry_Get: expected a *CloudService got %T", transport)
+	}
+	if cloudService.Environment != "prod" || cloudService.Credentials.Email != "test@test.com" {
+		t.Errorf("TestRegistry_Get: unexpected cloud service %+v", cloudService)
+	}
+
+	if _, err := registry.Get("shipping"); err == nil {
+		t.Errorf("TestRegistry_Get: expected an error for an unknown dependency")
+	}
+}
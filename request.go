@@ -1,7 +1,10 @@
 package microservicetransport
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/url"
 
 	"github.com/LUSHDigital/microservice-transport-golang/config"
@@ -13,10 +16,20 @@ type Request struct {
 	Method   string            // HTTP method/verb for the request.
 	Query    url.Values        // Query string values.
 	Resource string            // Endpoint/resource on the requested service.
+	Template string            // Template of the resource for metrics, e.g. orders/{id}, derived from the resource if empty.
 	Protocol string            // Transfer protocol to access the service with.
 	Headers  map[string]string // Headers to pass with the request.
 }
 
+// template - Get the template of the resource for metrics.
+func (r *Request) template() string {
+	if r.Template != "" {
+		return r.Template
+	}
+
+	return resourceTemplate(r.Resource)
+}
+
 // getProtocol - Get the transfer protocol to use for the service
 func (r *Request) getProtocol() string {
 	switch r.Protocol {
@@ -26,3 +39,19 @@ func (r *Request) getProtocol() string {
 		return config.ProtocolHTTP
 	}
 }
+
+// replayableBody - Read the request body into memory, so the HTTP request
+// built from it can be replayed.
+func (r *Request) replayableBody() (io.Reader, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read request body: %s", err)
+	}
+
+	return bytes.NewReader(body), nil
+}
@@ -0,0 +1,219 @@
+package microservicetransport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/LUSHDigital/microservice-transport-golang/domain"
+	"gopkg.in/yaml.v2"
+)
+
+// Resolver - Resolves a service identity to the base URLs of its instances.
+//
+// Base URLs without a scheme take the protocol of the request being made.
+type Resolver interface {
+	// Resolve - Get the base URLs of the instances of a service.
+	Resolve(ctx context.Context, identity Identity) ([]*url.URL, error)
+}
+
+// protocolKey - Key of the protocol of the request being resolved in a context.
+type protocolKey struct{}
+
+// ContextWithProtocol - Get a context telling resolvers the protocol the
+// service is going to be called with. Services set it on every resolution.
+func ContextWithProtocol(ctx context.Context, protocol string) context.Context {
+	return context.WithValue(ctx, protocolKey{}, protocol)
+}
+
+// ProtocolFromContext - Get the protocol the service is going to be called
+// with, or an empty string if it is not known.
+func ProtocolFromContext(ctx context.Context) string {
+	protocol, _ := ctx.Value(protocolKey{}).(string)
+	return protocol
+}
+
+// DefaultResolver - Resolver used by services which do not have their own.
+var DefaultResolver Resolver = DNSNameResolver{}
+
+// DNSNameResolver - Resolves services to the cluster DNS names built by
+// domain.BuildServiceDNSName, e.g. name-branch-env.name-version.
+type DNSNameResolver struct{}
+
+// Resolve - Get the base URL of the service from its DNS name. Identities
+// which cannot make a valid DNS name return an error wrapping
+// domain.ErrInvalidName.
+func (DNSNameResolver) Resolve(ctx context.Context, identity Identity) ([]*url.URL, error) {
+	// Determine the service namespace to use based on the service version.
+	serviceNamespace := identity.Name
+	if identity.Version != 0 {
+		serviceNamespace = fmt.Sprintf("%s-%d", serviceNamespace, identity.Version)
+	}
+
+	if err := domain.ValidateServiceDNSName(identity.Name, identity.Branch, identity.Environment, serviceNamespace); err != nil {
+		return nil, err
+	}
+
+	dnsName := domain.BuildServiceDNSName(identity.Name, identity.Branch, identity.Environment, serviceNamespace)
+
+	return []*url.URL{{Host: dnsName}}, nil
+}
+
+// StaticResolver - Resolves services from a fixed list of entries, so
+// services can be reached outside of the cluster.
+type StaticResolver struct {
+	Entries []StaticEntry `json:"services" yaml:"services"`
+}
+
+// StaticEntry - Base URLs for services matching an identity. Empty fields,
+// and a zero version, match any value.
+type StaticEntry struct {
+	Name        string   `json:"name" yaml:"name"`
+	Namespace   string   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Branch      string   `json:"branch,omitempty" yaml:"branch,omitempty"`
+	Environment string   `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Version     int      `json:"version,omitempty" yaml:"version,omitempty"`
+	URLs        []string `json:"urls" yaml:"urls"`
+}
+
+// LoadStaticResolver - Load a static resolver from JSON of the form:
+//
+//	{"services": [{"name": "orders", "version": 1, "urls": ["http://localhost:8081"]}]}
+func LoadStaticResolver(r io.Reader) (*StaticResolver, error) {
+	resolver := &StaticResolver{}
+	if err := json.NewDecoder(r).Decode(resolver); err != nil {
+		return nil, fmt.Errorf("cannot decode static resolver: %w", err)
+	}
+
+	if err := resolver.validate(); err != nil {
+		return nil, err
+	}
+
+	return resolver, nil
+}
+
+// LoadStaticResolverYAML - Load a static resolver from YAML of the form:
+//
+//	services:
+//	  - name: orders
+//	    version: 1
+//	    urls: ["http://localhost:8081"]
+func LoadStaticResolverYAML(r io.Reader) (*StaticResolver, error) {
+	resolver := &StaticResolver{}
+	if err := yaml.NewDecoder(r).Decode(resolver); err != nil {
+		return nil, fmt.Errorf("cannot decode static resolver: %w", err)
+	}
+
+	if err := resolver.validate(); err != nil {
+		return nil, err
+	}
+
+	return resolver, nil
+}
+
+// LoadStaticResolverFile - Load a static resolver from a file, which is read
+// as YAML if it has a .yaml or .yml extension and as JSON otherwise.
+func LoadStaticResolverFile(path string) (*StaticResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open static resolver: %w", err)
+	}
+	defer f.Close()
+
+	if isYAMLFile(path) {
+		return LoadStaticResolverYAML(f)
+	}
+
+	return LoadStaticResolver(f)
+}
+
+// isYAMLFile - Check whether a file should be read as YAML from its extension.
+func isYAMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// validate - Check every entry has a name and absolute base URLs.
+func (r *StaticResolver) validate() error {
+	for _, entry := range r.Entries {
+		if entry.Name == "" {
+			return fmt.Errorf("invalid static resolver entry: missing name")
+		}
+
+		if len(entry.URLs) == 0 {
+			return fmt.Errorf("invalid static resolver entry %s: missing urls", entry.Name)
+		}
+
+		for _, rawUrl := range entry.URLs {
+			if _, err := parseBaseUrl(rawUrl); err != nil {
+				return fmt.Errorf("invalid static resolver entry %s: %w", entry.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Resolve - Get the base URLs of the first entry matching the identity.
+func (r *StaticResolver) Resolve(ctx context.Context, identity Identity) ([]*url.URL, error) {
+	for _, entry := range r.Entries {
+		if !entry.matches(identity) {
+			continue
+		}
+
+		urls := make([]*url.URL, 0, len(entry.URLs))
+		for _, rawUrl := range entry.URLs {
+			u, err := parseBaseUrl(rawUrl)
+			if err != nil {
+				return nil, err
+			}
+			urls = append(urls, u)
+		}
+
+		return urls, nil
+	}
+
+	return nil, fmt.Errorf("no static entry for service %s", identity)
+}
+
+// matches - Check whether the entry applies to an identity.
+func (e StaticEntry) matches(identity Identity) bool {
+	return e.Name == identity.Name &&
+		(e.Namespace == "" || e.Namespace == identity.Namespace) &&
+		(e.Branch == "" || e.Branch == identity.Branch) &&
+		(e.Environment == "" || e.Environment == identity.Environment) &&
+		(e.Version == 0 || e.Version == identity.Version)
+}
+
+// parseBaseUrl - Parse an absolute base URL.
+func parseBaseUrl(rawUrl string) (*url.URL, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", rawUrl, err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q: must be absolute", rawUrl)
+	}
+
+	return u, nil
+}
+
+// buildResourceUrl - Build the URL of a resource on a service instance.
+func buildResourceUrl(base *url.URL, request *Request) string {
+	scheme := base.Scheme
+	if scheme == "" {
+		scheme = request.getProtocol()
+	}
+
+	return fmt.Sprintf("%s://%s%s/%s", scheme, base.Host, strings.TrimSuffix(base.Path, "/"), request.Resource)
+}
@@ -0,0 +1,255 @@
+package microservicetransport
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDNSNameResolver_Resolve(t *testing.T) {
+	tt := []struct {
+		name        string
+		identity    Identity
+		expectedUrl string
+	}{
+		{
+			name:        "Unversioned",
+			identity:    Identity{Name: "myservice", Namespace: "services", Branch: "master", Environment: "staging"},
+			expectedUrl: "//myservice-master-staging.myservice",
+		},
+		{
+			name:        "Versioned",
+			identity:    Identity{Name: "myservice", Namespace: "services", Branch: "master", Environment: "staging", Version: 2},
+			expectedUrl: "//myservice-master-staging.myservice-2",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			urls, err := DNSNameResolver{}.Resolve(context.Background(), tc.identity)
+			if err != nil {
+				t.Fatalf("TestDNSNameResolver_Resolve: %s: %s", tc.name, err)
+			}
+
+			if len(urls) != 1 || urls[0].String() != tc.expectedUrl {
+				t.Errorf("TestDNSNameResolver_Resolve: %s: expected %v got %v", tc.name, tc.expectedUrl, urls)
+			}
+		})
+	}
+}
+
+func TestLoadStaticResolver(t *testing.T) {
+	tt := []struct {
+		name        string
+		manifest    string
+		expectedErr bool
+	}{
+		{
+			name:     "Valid",
+			manifest: `{"services": [{"name": "orders", "urls": ["http://localhost:8081"]}]}`,
+		},
+		{
+			name:        "Not JSON",
+			manifest:    `services: []`,
+			expectedErr: true,
+		},
+		{
+			name:        "Missing name",
+			manifest:    `{"services": [{"urls": ["http://localhost:8081"]}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Missing urls",
+			manifest:    `{"services": [{"name": "orders"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Relative url",
+			manifest:    `{"services": [{"name": "orders", "urls": ["localhost:8081"]}]}`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadStaticResolver(strings.NewReader(tc.manifest))
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("TestLoadStaticResolver: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadStaticResolverYAML(t *testing.T) {
+	tt := []struct {
+		name        string
+		manifest    string
+		expectedErr bool
+	}{
+		{
+			name: "Valid",
+			manifest: `services:
+  - name: orders
+    version: 2
+    urls: ["http://localhost:8081"]
+`,
+		},
+		{
+			name:     "JSON",
+			manifest: `{"services": [{"name": "orders", "urls": ["http://localhost:8081"]}]}`,
+		},
+		{
+			name:        "Not YAML",
+			manifest:    `services: [`,
+			expectedErr: true,
+		},
+		{
+			name: "Missing urls",
+			manifest: `services:
+  - name: orders
+`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadStaticResolverYAML(strings.NewReader(tc.manifest))
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("TestLoadStaticResolverYAML: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadStaticResolverFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tt := []struct {
+		name     string
+		file     string
+		manifest string
+	}{
+		{
+			name:     "JSON",
+			file:     "services.json",
+			manifest: `{"services": [{"name": "orders", "urls": ["http://localhost:8081"]}]}`,
+		},
+		{
+			name:     "YAML",
+			file:     "services.yaml",
+			manifest: "services:\n  - name: orders\n    urls: [\"http://localhost:8081\"]\n",
+		},
+		{
+			name:     "YML",
+			file:     "services.YML",
+			manifest: "services:\n  - name: orders\n    urls:\n      - http://localhost:8081\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.file)
+			if err := os.WriteFile(path, []byte(tc.manifest), 0600); err != nil {
+				t.Fatalf("TestLoadStaticResolverFile: %s: %s", tc.name, err)
+			}
+
+			resolver, err := LoadStaticResolverFile(path)
+			if err != nil {
+				t.Fatalf("TestLoadStaticResolverFile: %s: %s", tc.name, err)
+			}
+
+			urls, err := resolver.Resolve(context.Background(), Identity{Name: "orders"})
+			if err != nil {
+				t.Fatalf("TestLoadStaticResolverFile: %s: %s", tc.name, err)
+			}
+			if len(urls) != 1 || urls[0].String() != "http://localhost:8081" {
+				t.Errorf("TestLoadStaticResolverFile: %s: expected http://localhost:8081 got %v", tc.name, urls)
+			}
+		})
+	}
+
+	if _, err := LoadStaticResolverFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("TestLoadStaticResolverFile: expected an error for a missing file")
+	}
+}
+
+func TestStaticResolver_Resolve(t *testing.T) {
+	resolver, err := LoadStaticResolver(strings.NewReader(`{"services": [
+		{"name": "orders", "version": 2, "urls": ["http://orders-v2.local"]},
+		{"name": "orders", "urls": ["http://localhost:8081", "http://localhost:8082"]}
+	]}`))
+	if err != nil {
+		t.Fatalf("TestStaticResolver_Resolve: %s", err)
+	}
+
+	tt := []struct {
+		name         string
+		identity     Identity
+		expectedUrls []string
+		expectedErr  bool
+	}{
+		{
+			name:         "Versioned entry",
+			identity:     Identity{Name: "orders", Version: 2},
+			expectedUrls: []string{"http://orders-v2.local"},
+		},
+		{
+			name:         "Catch all entry",
+			identity:     Identity{Name: "orders", Version: 1},
+			expectedUrls: []string{"http://localhost:8081", "http://localhost:8082"},
+		},
+		{
+			name:        "Unknown service",
+			identity:    Identity{Name: "payments"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			urls, err := resolver.Resolve(context.Background(), tc.identity)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("TestStaticResolver_Resolve: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+
+			actualUrls := []string{}
+			for _, u := range urls {
+				actualUrls = append(actualUrls, u.String())
+			}
+
+			if !tc.expectedErr && strings.Join(actualUrls, ",") != strings.Join(tc.expectedUrls, ",") {
+				t.Errorf("TestStaticResolver_Resolve: %s: expected %v got %v", tc.name, tc.expectedUrls, actualUrls)
+			}
+		})
+	}
+}
+
+func TestService_Dial_resolver(t *testing.T) {
+	resolver, _ := LoadStaticResolver(strings.NewReader(`{"services": [{"name": "orders", "urls": ["https://localhost:8081/base/"]}]}`))
+
+	service := NewService(DefaultHttpClient(), "master", "staging", "services", "orders")
+	service.Resolver = resolver
+
+	err := service.Dial(&Request{
+		Method:   http.MethodGet,
+		Resource: "things",
+		Protocol: "http",
+	})
+	if err != nil {
+		t.Fatalf("TestService_Dial_resolver: %s", err)
+	}
+
+	expectedUrl := "https://localhost:8081/base/things"
+	if service.CurrentRequest.URL.String() != expectedUrl {
+		t.Errorf("TestService_Dial_resolver: expected %v got %v", expectedUrl, service.CurrentRequest.URL.String())
+	}
+
+	service.Name = "payments"
+	if err := service.Dial(&Request{Method: http.MethodGet, Resource: "things"}); err == nil {
+		t.Errorf("TestService_Dial_resolver: expected an error for an unknown service")
+	}
+}
@@ -0,0 +1,174 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	transportErrors "github.com/LUSHDigital/microservice-transport-golang/errors"
+)
+
+// RetryPolicy - Decides whether, and how soon, a failed call is attempted again.
+type RetryPolicy struct {
+	MaxAttempts        int           // Maximum number of attempts, including the first.
+	BaseDelay          time.Duration // Delay before the first retry, doubled for each retry after.
+	MaxDelay           time.Duration // Longest delay allowed between attempts, no limit if 0.
+	Jitter             float64       // Fraction of each delay to randomise, from 0 to 1.
+	Methods            []string      // HTTP methods to retry, any allowed method if empty.
+	StatusCodes        []int         // Response status codes to retry.
+	RetryNonIdempotent bool          // Allow POST and PATCH requests to be retried.
+}
+
+// DefaultRetryPolicy - Get a retry policy which makes up to 3 attempts at
+// idempotent requests failing with a connection error or gateway error.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		Jitter:      0.2,
+		StatusCodes: []int{
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// next - Decide whether to make another attempt after the provided one, and
+// how long to wait before doing so.
+func (r *RetryPolicy) next(attempt int, method string, resp *http.Response, err error) (time.Duration, bool) {
+	if r == nil || attempt >= r.MaxAttempts || !r.allowsMethod(method) {
+		return 0, false
+	}
+
+	delay := r.backoff(attempt)
+
+	switch {
+	// The caller gave up, so there is no point trying again.
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return 0, false
+
+	// The circuit breaker will keep rejecting calls until it cools down.
+	case errors.As(err, &transportErrors.CircuitOpenError{}):
+		return 0, false
+
+	// Connection errors are always worth another go.
+	case err != nil:
+		return delay, true
+
+	case !r.allowsStatus(resp.StatusCode):
+		return 0, false
+	}
+
+	// Honour any delay the service asked for, unless it is more than we are
+	// prepared to wait.
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		if r.MaxDelay > 0 && retryAfter > r.MaxDelay {
+			return 0, false
+		}
+		delay = retryAfter
+	}
+
+	return delay, true
+}
+
+// backoff - Get the exponential backoff delay after the provided attempt.
+func (r *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := r.BaseDelay << uint(attempt-1)
+
+	// Doubling for long enough overflows, so hold the delay at its longest.
+	if attempt > 64 || delay>>uint(attempt-1) != r.BaseDelay {
+		delay = math.MaxInt64
+	}
+
+	if r.MaxDelay > 0 && delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+
+	if r.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * r.Jitter * float64(delay))
+	}
+
+	return delay
+}
+
+// allowsMethod - Check whether requests with the provided method may be retried.
+func (r *RetryPolicy) allowsMethod(method string) bool {
+	if !isIdempotent(method) && !r.RetryNonIdempotent {
+		return false
+	}
+
+	if len(r.Methods) == 0 {
+		return true
+	}
+
+	for _, allowed := range r.Methods {
+		if allowed == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowsStatus - Check whether responses with the provided status may be retried.
+func (r *RetryPolicy) allowsStatus(code int) bool {
+	for _, allowed := range r.StatusCodes {
+		if allowed == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isIdempotent - Check whether repeating a request with the provided method
+// has the same effect as making it once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter - Parse a Retry-After header value, given either in
+// seconds or as a HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// sleep - Wait for the provided delay, or until the context is done.
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,271 @@
+package microservicetransport
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_next(t *testing.T) {
+	tt := []struct {
+		name          string
+		policy        *RetryPolicy
+		attempt       int
+		method        string
+		status        int
+		retryAfter    string
+		err           error
+		expectedRetry bool
+		expectedDelay time.Duration
+	}{
+		{
+			name:          "No policy",
+			attempt:       1,
+			method:        http.MethodGet,
+			status:        http.StatusServiceUnavailable,
+			expectedRetry: false,
+		},
+		{
+			name:          "GET 503",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, StatusCodes: []int{503}},
+			attempt:       2,
+			method:        http.MethodGet,
+			status:        http.StatusServiceUnavailable,
+			expectedRetry: true,
+			expectedDelay: 2 * time.Second,
+		},
+		{
+			name:          "GET 500 not retryable",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, StatusCodes: []int{503}},
+			attempt:       1,
+			method:        http.MethodGet,
+			status:        http.StatusInternalServerError,
+			expectedRetry: false,
+		},
+		{
+			name:          "Attempts exhausted",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, StatusCodes: []int{503}},
+			attempt:       3,
+			method:        http.MethodGet,
+			status:        http.StatusServiceUnavailable,
+			expectedRetry: false,
+		},
+		{
+			name:          "Backoff capped",
+			policy:        &RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second, StatusCodes: []int{503}},
+			attempt:       6,
+			method:        http.MethodGet,
+			status:        http.StatusServiceUnavailable,
+			expectedRetry: true,
+			expectedDelay: 5 * time.Second,
+		},
+		{
+			name:          "No cap",
+			policy:        &RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, StatusCodes: []int{503}},
+			attempt:       6,
+			method:        http.MethodGet,
+			status:        http.StatusServiceUnavailable,
+			expectedRetry: true,
+			expectedDelay: 32 * time.Second,
+		},
+		{
+			name:          "No cap with Retry-After",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, StatusCodes: []int{503}},
+			attempt:       1,
+			method:        http.MethodGet,
+			status:        http.StatusServiceUnavailable,
+			retryAfter:    "120",
+			expectedRetry: true,
+			expectedDelay: 2 * time.Minute,
+		},
+		{
+			name:          "No cap overflowing",
+			policy:        &RetryPolicy{MaxAttempts: 100, BaseDelay: time.Second, StatusCodes: []int{503}},
+			attempt:       70,
+			method:        http.MethodGet,
+			status:        http.StatusServiceUnavailable,
+			expectedRetry: true,
+			expectedDelay: math.MaxInt64,
+		},
+		{
+			name:          "POST without opt in",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, StatusCodes: []int{503}},
+			attempt:       1,
+			method:        http.MethodPost,
+			status:        http.StatusServiceUnavailable,
+			expectedRetry: false,
+		},
+		{
+			name:          "POST with opt in",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, StatusCodes: []int{503}, RetryNonIdempotent: true},
+			attempt:       1,
+			method:        http.MethodPost,
+			status:        http.StatusServiceUnavailable,
+			expectedRetry: true,
+			expectedDelay: time.Second,
+		},
+		{
+			name:          "Method not listed",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, StatusCodes: []int{503}, Methods: []string{http.MethodGet}},
+			attempt:       1,
+			method:        http.MethodDelete,
+			status:        http.StatusServiceUnavailable,
+			expectedRetry: false,
+		},
+		{
+			name:          "Connection error",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute},
+			attempt:       1,
+			method:        http.MethodGet,
+			err:           errors.New("connection refused"),
+			expectedRetry: true,
+			expectedDelay: time.Second,
+		},
+		{
+			name:          "Context cancelled",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute},
+			attempt:       1,
+			method:        http.MethodGet,
+			err:           context.Canceled,
+			expectedRetry: false,
+		},
+		{
+			name:          "Retry-After seconds",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, StatusCodes: []int{503}},
+			attempt:       1,
+			method:        http.MethodGet,
+			status:        http.StatusServiceUnavailable,
+			retryAfter:    "7",
+			expectedRetry: true,
+			expectedDelay: 7 * time.Second,
+		},
+		{
+			name:          "Retry-After too long",
+			policy:        &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, StatusCodes: []int{503}},
+			attempt:       1,
+			method:        http.MethodGet,
+			status:        http.StatusServiceUnavailable,
+			retryAfter:    "120",
+			expectedRetry: false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp *http.Response
+			if tc.err == nil {
+				resp = &http.Response{StatusCode: tc.status, Header: http.Header{}}
+				if tc.retryAfter != "" {
+					resp.Header.Set("Retry-After", tc.retryAfter)
+				}
+			}
+
+			delay, retry := tc.policy.next(tc.attempt, tc.method, resp, tc.err)
+			if retry != tc.expectedRetry {
+				t.Fatalf("TestRetryPolicy_next: %s: expected retry %v got %v", tc.name, tc.expectedRetry, retry)
+			}
+
+			if retry && delay != tc.expectedDelay {
+				t.Errorf("TestRetryPolicy_next: %s: expected delay %v got %v", tc.name, tc.expectedDelay, delay)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_backoff_jitter(t *testing.T) {
+	policy := &RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(3)
+		if delay < 2*time.Second || delay > 4*time.Second {
+			t.Fatalf("TestRetryPolicy_backoff_jitter: expected delay between %v and %v got %v", 2*time.Second, 4*time.Second, delay)
+		}
+	}
+}
+
+func TestPreparedCall_Do_retry(t *testing.T) {
+	tt := []struct {
+		name             string
+		method           string
+		failures         int
+		expectedStatus   int
+		expectedAttempts int
+	}{
+		{
+			name:             "PUT recovers",
+			method:           http.MethodPut,
+			failures:         2,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "PUT gives up",
+			method:           http.MethodPut,
+			failures:         5,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "POST not retried",
+			method:           http.MethodPost,
+			failures:         1,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Start a HTTP server which fails a number of times before
+			// echoing back the request body.
+			var attempts int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+
+				body, _ := ioutil.ReadAll(r.Body)
+				if string(body) != "payload" {
+					t.Errorf("TestPreparedCall_Do_retry: %s: attempt %d: expected body %v got %v", tc.name, attempts, "payload", string(body))
+				}
+
+				if attempts <= tc.failures {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+			}))
+			defer ts.Close()
+
+			service := NewService(redirectClient(ts), "master", "staging", "services", "myservice")
+			service.Retry = DefaultRetryPolicy()
+
+			call, err := service.Prepare(context.Background(), &Request{
+				Method:   tc.method,
+				Resource: "things",
+				Body:     ioutil.NopCloser(strings.NewReader("payload")),
+			})
+			if err != nil {
+				t.Fatalf("TestPreparedCall_Do_retry: %s: %s", tc.name, err)
+			}
+
+			resp, err := call.Do(context.Background())
+			if err != nil {
+				t.Fatalf("TestPreparedCall_Do_retry: %s: %s", tc.name, err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("TestPreparedCall_Do_retry: %s: expected status %v got %v", tc.name, tc.expectedStatus, resp.StatusCode)
+			}
+
+			if attempts != tc.expectedAttempts {
+				t.Errorf("TestPreparedCall_Do_retry: %s: expected attempts %v got %v", tc.name, tc.expectedAttempts, attempts)
+			}
+		})
+	}
+}
@@ -1,23 +1,53 @@
 package microservicetransport
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/LUSHDigital/microservice-transport-golang/config"
-	"github.com/LUSHDigital/microservice-transport-golang/domain"
 )
 
 // Service - Responsible for communication with a service.
 type Service struct {
-	Branch         string        // VCS branch the service is built from.
-	CurrentRequest *http.Request // Current HTTP request being actioned.
-	Environment    string        // CI environment the service operates in.
-	Namespace      string        // Namespace of the service.
-	Name           string        // Name of the service.
-	Version        int           // Major API version of the service.
-	Client         *http.Client  // http client implementation
+	Branch           string                // VCS branch the service is built from.
+	CurrentRequest   *http.Request         // Current HTTP request being actioned.
+	Environment      string                // CI environment the service operates in.
+	Namespace        string                // Namespace of the service.
+	Name             string                // Name of the service.
+	Version          int                   // Major API version of the service.
+	Protocol         string                // Transfer protocol for requests which do not set their own.
+	Client           *http.Client          // http client implementation
+	Retry            *RetryPolicy          // Policy for retrying failed calls, nil to never retry.
+	CircuitBreaker   *CircuitBreakerPolicy // Policy for the circuit breaker around calls, nil to disable it.
+	Resolver         Resolver              // Resolver to find the service with, DefaultResolver if nil.
+	Balancer         Balancer              // Balancer choosing between instances of the service, the first is always used if nil.
+	OutlierDetection *OutlierPolicy        // Policy for ejecting failing instances of the service, nil to never eject them.
+	Config           *config.Config        // Configuration of the service environment, read from the environment if nil.
+	Overrides        *Overrides            // Overrides consulted before resolving the service, DefaultOverrides if nil.
+	Interceptors     []Interceptor         // Interceptors every request to the service goes through, in order.
+	ForwardHeaders   []string              // Inbound headers forwarded on calls with the request ID, DefaultForwardedHeaders if nil.
+	Metrics          MetricsCollector      // Collector of metrics for calls and gateway logins, nil to not record any.
+	Logger           *CallLogger           // Logger of calls and gateway logins, nil to not log them.
+	Tracer           *Tracer               // Tracer of calls, nil to not trace them.
+
+	current *PreparedCall // Prepared call behind CurrentRequest.
+}
+
+// Identity - Identifies a deployment of a service.
+type Identity struct {
+	Name        string // Name the service is addressed by.
+	Namespace   string // Namespace of the service.
+	Branch      string // VCS branch the service is built from.
+	Environment string // CI environment the service operates in.
+	Version     int    // Major API version of the service.
+}
+
+// String - Get a readable representation of the identity.
+func (i Identity) String() string {
+	return fmt.Sprintf("%s/%s@%s-%s/v%d", i.Namespace, i.Name, i.Branch, i.Environment, i.Version)
 }
 
 // NewService - prepares a new service with the provided parameters and client.
@@ -33,48 +63,169 @@ func NewService(client *http.Client, branch, env, namespace, name string) *Servi
 
 // Call - Do the current service request.
 func (s *Service) Call() (*http.Response, error) {
-	return s.Client.Do(s.CurrentRequest)
+	return s.CallContext(s.CurrentRequest.Context())
+}
+
+// CallContext - Do the current service request, bound to the provided context.
+func (s *Service) CallContext(ctx context.Context) (*http.Response, error) {
+	return s.currentCall(s.Client).Do(ctx)
 }
 
 // Dial - Create a request to a service resource.
 func (s *Service) Dial(request *Request) error {
-	var err error
+	return s.DialContext(context.Background(), request)
+}
 
-	// Make any alterations based upon the namespace.
-	switch s.Namespace {
-	case "aggregators":
-		s.Name = strings.Join([]string{config.AggregatorDomainPrefix, s.Name}, "-")
+// DialContext - Create a request to a service resource, bound to the provided
+// context.
+func (s *Service) DialContext(ctx context.Context, request *Request) error {
+	call, err := s.Prepare(ctx, request)
+	if err != nil {
+		return err
 	}
 
-	// Determine the service namespace to use based on the service version.
-	serviceNamespace := s.Name
-	if s.Version != 0 {
-		serviceNamespace = fmt.Sprintf("%s-%d", serviceNamespace, s.Version)
-	}
+	s.setCurrentCall(call)
 
-	// Get the name of the service.
-	dnsName := domain.BuildServiceDNSName(s.Name, s.Branch, s.Environment, serviceNamespace)
+	return nil
+}
 
-	// Build the resource URL.
-	resourceUrl := fmt.Sprintf("%s://%s/%s", request.getProtocol(), dnsName, request.Resource)
+// Prepare - Prepare a call to a service resource, bound to the provided
+// context. The service itself is left untouched, so it can prepare calls for
+// many goroutines at once.
+func (s *Service) Prepare(ctx context.Context, request *Request) (*PreparedCall, error) {
+	request = s.withProtocol(request)
+	identity := s.GetIdentity()
 
-	// Append the query string if we have any.
-	if len(request.Query) > 0 {
-		resourceUrl = fmt.Sprintf("%s?%s", resourceUrl, request.Query.Encode())
+	// Find where the service lives, unless it is overridden.
+	override, err := s.override()
+	if err != nil {
+		return nil, err
 	}
 
-	// Create the request.
-	s.CurrentRequest, err = http.NewRequest(request.Method, resourceUrl, request.Body)
+	baseUrls := []*url.URL{override}
+	if override == nil {
+		baseUrls, err = s.getResolver().Resolve(ContextWithProtocol(ctx, request.getProtocol()), identity)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve service %s: %w", identity, err)
+		}
+		if len(baseUrls) == 0 {
+			return nil, fmt.Errorf("cannot resolve service %s: no instances found", identity)
+		}
+	}
+
+	call, err := s.prepareAt(ctx, s.Client, request, baseUrls)
+	if err != nil {
+		return nil, err
+	}
 
 	// Add the headers.
 	for key, value := range request.Headers {
-		s.CurrentRequest.Header.Set(key, value)
+		call.HTTPRequest.Header.Set(key, value)
 	}
 
-	return err
+	return call, nil
+}
+
+// prepareAt - Prepare a call to a service resource on the instances at the
+// provided base URLs.
+func (s *Service) prepareAt(ctx context.Context, client *http.Client, request *Request, baseUrls []*url.URL) (*PreparedCall, error) {
+	// Build the resource URL on each instance.
+	resourceUrls := make([]string, 0, len(baseUrls))
+	for _, baseUrl := range baseUrls {
+		resourceUrls = append(resourceUrls, buildResourceUrl(baseUrl, request))
+	}
+
+	return newPreparedCall(ctx, s, client, request, resourceUrls)
+}
+
+// override - Get the base URL of the override for the service, or nil if it
+// is not overridden. Overrides match the name of the service as it was
+// created, rather than the name it is addressed by.
+func (s *Service) override() (*url.URL, error) {
+	overrides := s.Overrides
+	if overrides == nil {
+		var err error
+		if overrides, err = DefaultOverrides(); err != nil {
+			return nil, err
+		}
+	}
+
+	identity := s.GetIdentity()
+	identity.Name = s.Name
+
+	override, _ := overrides.lookup(identity)
+
+	return override, nil
 }
 
 // Dial - Get the name of the service
 func (s *Service) GetName() string {
 	return s.Name
 }
+
+// GetIdentity - Get the identity of the deployment the service resolves to.
+func (s *Service) GetIdentity() Identity {
+	return Identity{
+		Name:        s.resolvedName(),
+		Namespace:   s.Namespace,
+		Branch:      s.Branch,
+		Environment: s.Environment,
+		Version:     s.Version,
+	}
+}
+
+// withProtocol - Get the request with the protocol of the service, if the
+// request does not set its own. The provided request is left untouched.
+func (s *Service) withProtocol(request *Request) *Request {
+	if request.Protocol != "" || s.Protocol == "" {
+		return request
+	}
+
+	withProtocol := *request
+	withProtocol.Protocol = s.Protocol
+
+	return &withProtocol
+}
+
+// getResolver - Get the resolver used to find the service.
+func (s *Service) getResolver() Resolver {
+	if s.Resolver != nil {
+		return s.Resolver
+	}
+
+	return DefaultResolver
+}
+
+// resolvedName - Get the name the service is addressed by, which differs from
+// its name for some namespaces.
+func (s *Service) resolvedName() string {
+	// Make any alterations based upon the namespace.
+	switch s.Namespace {
+	case "aggregators":
+		return strings.Join([]string{config.AggregatorDomainPrefix, s.Name}, "-")
+	}
+
+	return s.Name
+}
+
+// currentCall - Get the prepared call for the current request, wrapping the
+// current request in a new call if it was not built by DialContext.
+func (s *Service) currentCall(client *http.Client) *PreparedCall {
+	if s.current != nil && s.current.HTTPRequest == s.CurrentRequest {
+		return s.current
+	}
+
+	return &PreparedCall{
+		HTTPRequest:  s.CurrentRequest,
+		service:      s,
+		identity:     s.GetIdentity(),
+		client:       client,
+		interceptors: s.Interceptors,
+	}
+}
+
+// setCurrentCall - Make a prepared call the current service request.
+func (s *Service) setCurrentCall(call *PreparedCall) {
+	s.current = call
+	s.CurrentRequest = call.HTTPRequest
+}
@@ -2,16 +2,23 @@ package microservicetransport
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 
 	"fmt"
 
 	"github.com/LUSHDigital/microservice-core-golang/response"
 	"github.com/LUSHDigital/microservice-transport-golang/config"
+	"github.com/LUSHDigital/microservice-transport-golang/domain"
 )
 
 func TestService_Dial(t *testing.T) {
@@ -270,6 +277,180 @@ func TestService_GetName(t *testing.T) {
 	}
 }
 
+func TestService_DialContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	service := Service{
+		Branch:      "master",
+		Environment: "staging",
+		Namespace:   "services",
+		Name:        "myservice",
+		Client:      DefaultHttpClient(),
+	}
+
+	err := service.DialContext(ctx, &Request{
+		Method:   http.MethodGet,
+		Resource: "things",
+	})
+	if err != nil {
+		t.Fatalf("TestService_DialContext: %s", err)
+	}
+
+	if service.CurrentRequest.Context() != ctx {
+		t.Errorf("TestService_DialContext: expected the request to carry the dial context")
+	}
+
+	_, err = service.Call()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("TestService_DialContext: expected %v got %v", context.Canceled, err)
+	}
+}
+
+func TestService_Dial_aggregator(t *testing.T) {
+	service := Service{
+		Branch:      "master",
+		Environment: "staging",
+		Namespace:   "aggregators",
+		Name:        "myaggregator",
+	}
+
+	for i := 0; i < 2; i++ {
+		err := service.Dial(&Request{
+			Method:   http.MethodGet,
+			Resource: "things",
+		})
+		if err != nil {
+			t.Fatalf("TestService_Dial_aggregator: %s", err)
+		}
+
+		expectedUrl := "http://agg-myaggregator-master-staging.agg-myaggregator/things"
+		if service.CurrentRequest.URL.String() != expectedUrl {
+			t.Errorf("TestService_Dial_aggregator: expected %v got %v", expectedUrl, service.CurrentRequest.URL.String())
+		}
+
+		if service.GetName() != "myaggregator" {
+			t.Errorf("TestService_Dial_aggregator: expected %v got %v", "myaggregator", service.GetName())
+		}
+	}
+}
+
+func TestService_Dial_names(t *testing.T) {
+	tt := []struct {
+		name        string
+		service     Service
+		expectedUrl string
+		expectedErr error
+	}{
+		{
+			name: "Normalised branch",
+			service: Service{
+				Branch:      "feature/ABC-12_new",
+				Environment: "staging",
+				Namespace:   "services",
+				Name:        "my_service",
+			},
+			expectedUrl: "http://my-service-feature-abc-12-new-staging.my-service/things",
+		},
+		{
+			name: "Missing branch",
+			service: Service{
+				Environment: "staging",
+				Namespace:   "services",
+				Name:        "myservice",
+			},
+			expectedErr: domain.ErrInvalidName,
+		},
+		{
+			name: "Invalid name",
+			service: Service{
+				Branch:      "master",
+				Environment: "staging",
+				Namespace:   "services",
+				Name:        "!!!",
+			},
+			expectedErr: domain.ErrInvalidName,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.service.Dial(&Request{
+				Method:   http.MethodGet,
+				Resource: "things",
+			})
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("TestService_Dial_names: %s: expected %v got %v", tc.name, tc.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if tc.service.CurrentRequest.URL.String() != tc.expectedUrl {
+				t.Errorf("TestService_Dial_names: %s: expected %v got %v", tc.name, tc.expectedUrl, tc.service.CurrentRequest.URL.String())
+			}
+		})
+	}
+}
+
+func TestService_Prepare_concurrent(t *testing.T) {
+	// Start a HTTP server to act as every service, echoing back the path.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	service := NewService(redirectClient(ts), "master", "staging", "services", "myservice")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			resource := fmt.Sprintf("things/%d", i)
+			call, err := service.Prepare(context.Background(), &Request{
+				Method:   http.MethodGet,
+				Resource: resource,
+			})
+			if err != nil {
+				t.Errorf("TestService_Prepare_concurrent: %s", err)
+				return
+			}
+
+			resp, err := call.Do(context.Background())
+			if err != nil {
+				t.Errorf("TestService_Prepare_concurrent: %s", err)
+				return
+			}
+			defer resp.Body.Close()
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			if string(body) != "/"+resource {
+				t.Errorf("TestService_Prepare_concurrent: expected %v got %v", "/"+resource, string(body))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if service.CurrentRequest != nil {
+		t.Errorf("TestService_Prepare_concurrent: expected no current request, got %v", service.CurrentRequest)
+	}
+}
+
+// redirectClient - Get a http client which sends every request to the test
+// server, whatever host it is addressed to.
+func redirectClient(ts *httptest.Server) *http.Client {
+	client := DefaultHttpClient()
+	client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+		},
+	}
+
+	return client
+}
+
 func ExampleService_Dial() {
 	// Instantiate the service.
 	myService := &Service{
@@ -0,0 +1,176 @@
+package microservicetransport
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/dnssrv"
+)
+
+// SRVResolver - Resolves services to their instances and ports from the DNS
+// SRV records of the name DNSNameResolver builds for them. The name is
+// relative, so it resolves through the search domains of the DNS client,
+// e.g. svc.cluster.local.
+//
+// Records are cached for their TTL, and every resolution orders the instances
+// by priority, shuffling those of equal priority by weight, so that calls are
+// spread across instances as RFC 2782 describes.
+// An SRVResolver is safe for concurrent use, and may be built as a literal
+// as long as it has a Client.
+type SRVResolver struct {
+	Client  *dnssrv.Client // DNS client used for lookups, required.
+	Service string         // SRV service label, e.g. "http", the bare name is queried if empty.
+	Proto   string         // SRV protocol label, "tcp" if empty.
+
+	mu    sync.Mutex
+	cache map[string]srvCacheEntry
+	now   func() time.Time
+}
+
+// srvCacheEntry - SRV records cached for a name.
+type srvCacheEntry struct {
+	records   []dnssrv.Record
+	expiresAt time.Time
+}
+
+// NewSRVResolver - Prepare a new SRV resolver using the provided DNS client.
+func NewSRVResolver(client *dnssrv.Client) *SRVResolver {
+	return &SRVResolver{
+		Client: client,
+		cache:  make(map[string]srvCacheEntry),
+		now:    time.Now,
+	}
+}
+
+// Resolve - Get the base URLs of the instances of the service.
+func (r *SRVResolver) Resolve(ctx context.Context, identity Identity) ([]*url.URL, error) {
+	hosts, err := DNSNameResolver{}.Resolve(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	name := hosts[0].Host
+	if r.Service != "" {
+		proto := r.Proto
+		if proto == "" {
+			proto = "tcp"
+		}
+		name = fmt.Sprintf("_%s._%s.%s", r.Service, proto, name)
+	}
+
+	records, err := r.lookup(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot look up srv records for %s: %w", name, err)
+	}
+
+	urls := make([]*url.URL, 0, len(records))
+	for _, record := range orderSRV(records) {
+		urls = append(urls, &url.URL{
+			Host: net.JoinHostPort(record.Target, strconv.Itoa(int(record.Port))),
+		})
+	}
+
+	return urls, nil
+}
+
+// lookup - Get the SRV records for a name, from the cache if they are fresh.
+func (r *SRVResolver) lookup(ctx context.Context, name string) ([]dnssrv.Record, error) {
+	if r.Client == nil {
+		return nil, fmt.Errorf("srv resolver has no dns client")
+	}
+
+	r.mu.Lock()
+	entry, ok := r.cache[name]
+	r.mu.Unlock()
+
+	if ok && r.currentTime().Before(entry.expiresAt) {
+		return entry.records, nil
+	}
+
+	records, err := r.Client.LookupSRV(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the records for as long as the shortest TTL allows.
+	ttl := records[0].TTL
+	for _, record := range records[1:] {
+		if record.TTL < ttl {
+			ttl = record.TTL
+		}
+	}
+
+	r.mu.Lock()
+	if r.cache == nil {
+		r.cache = make(map[string]srvCacheEntry)
+	}
+	r.cache[name] = srvCacheEntry{
+		records:   records,
+		expiresAt: r.currentTime().Add(ttl),
+	}
+	r.mu.Unlock()
+
+	return records, nil
+}
+
+// currentTime - Get the time from the resolver clock, or the wall clock if
+// it has none.
+func (r *SRVResolver) currentTime() time.Time {
+	if r.now == nil {
+		return time.Now()
+	}
+
+	return r.now()
+}
+
+// orderSRV - Order records by priority, shuffling records of the same
+// priority so that each is picked first in proportion to its weight.
+func orderSRV(records []dnssrv.Record) []dnssrv.Record {
+	ordered := append([]dnssrv.Record(nil), records...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority < ordered[j].Priority
+	})
+
+	for start := 0; start < len(ordered); {
+		end := start
+		for end < len(ordered) && ordered[end].Priority == ordered[start].Priority {
+			end++
+		}
+		shuffleByWeight(ordered[start:end])
+		start = end
+	}
+
+	return ordered
+}
+
+// shuffleByWeight - Shuffle records so that each position is filled by a
+// record chosen with probability proportional to its weight.
+func shuffleByWeight(records []dnssrv.Record) {
+	for i := range records {
+		total := 0
+		for _, record := range records[i:] {
+			total += int(record.Weight)
+		}
+
+		// Records with no weight are only picked once the rest are used up.
+		if total == 0 {
+			return
+		}
+
+		pick := rand.Intn(total)
+		for j := i; j < len(records); j++ {
+			pick -= int(records[j].Weight)
+			if pick < 0 {
+				records[i], records[j] = records[j], records[i]
+				break
+			}
+		}
+	}
+}
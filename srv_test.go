@@ -0,0 +1,219 @@
+package microservicetransport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/dnssrv"
+	"github.com/LUSHDigital/microservice-transport-golang/dnssrv/dnssrvtest"
+)
+
+func TestSRVResolver_Resolve_cache(t *testing.T) {
+	server, err := dnssrvtest.NewServer(map[string][]dnssrv.Record{
+		"orders-master-staging.orders": {
+			{Target: "orders-0.local", Port: 8081, TTL: 30 * time.Second},
+			{Target: "orders-1.local", Port: 8082, TTL: 10 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("TestSRVResolver_Resolve_cache: %s", err)
+	}
+	defer server.Close()
+
+	now := time.Now()
+	resolver := NewSRVResolver(&dnssrv.Client{Server: server.Addr})
+	resolver.now = func() time.Time { return now }
+
+	identity := Identity{Name: "orders", Namespace: "services", Branch: "master", Environment: "staging"}
+
+	urls, err := resolver.Resolve(context.Background(), identity)
+	if err != nil {
+		t.Fatalf("TestSRVResolver_Resolve_cache: %s", err)
+	}
+	if len(urls) != 2 {
+		t.Fatalf("TestSRVResolver_Resolve_cache: expected 2 urls got %v", urls)
+	}
+
+	// Within the shortest TTL the cached records are used.
+	now = now.Add(9 * time.Second)
+	if _, err := resolver.Resolve(context.Background(), identity); err != nil {
+		t.Fatalf("TestSRVResolver_Resolve_cache: %s", err)
+	}
+	if server.Queries() != 1 {
+		t.Errorf("TestSRVResolver_Resolve_cache: expected 1 query got %d", server.Queries())
+	}
+
+	// Once it passes the records are looked up again.
+	now = now.Add(2 * time.Second)
+	server.SetRecords("orders-master-staging.orders", []dnssrv.Record{
+		{Target: "orders-2.local", Port: 8083, TTL: 10 * time.Second},
+	})
+	urls, err = resolver.Resolve(context.Background(), identity)
+	if err != nil {
+		t.Fatalf("TestSRVResolver_Resolve_cache: %s", err)
+	}
+	if server.Queries() != 2 {
+		t.Errorf("TestSRVResolver_Resolve_cache: expected 2 queries got %d", server.Queries())
+	}
+	if len(urls) != 1 || urls[0].Host != "orders-2.local:8083" {
+		t.Errorf("TestSRVResolver_Resolve_cache: expected orders-2.local:8083 got %v", urls)
+	}
+
+	// Records with no TTL are never cached.
+	server.SetRecords("orders-master-staging.orders", []dnssrv.Record{
+		{Target: "orders-2.local", Port: 8083},
+	})
+	now = now.Add(time.Minute)
+	resolver.Resolve(context.Background(), identity)
+	resolver.Resolve(context.Background(), identity)
+	if server.Queries() != 4 {
+		t.Errorf("TestSRVResolver_Resolve_cache: expected 4 queries got %d", server.Queries())
+	}
+
+	identity.Name = "payments"
+	if _, err := resolver.Resolve(context.Background(), identity); err == nil {
+		t.Errorf("TestSRVResolver_Resolve_cache: expected an error for an unknown service")
+	}
+}
+
+func TestSRVResolver_Resolve_service(t *testing.T) {
+	server, err := dnssrvtest.NewServer(map[string][]dnssrv.Record{
+		"_http._tcp.orders-master-staging.orders-2": {
+			{Target: "orders-0.local", Port: 8081, TTL: 30 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("TestSRVResolver_Resolve_service: %s", err)
+	}
+	defer server.Close()
+
+	resolver := NewSRVResolver(&dnssrv.Client{Server: server.Addr})
+	resolver.Service = "http"
+
+	urls, err := resolver.Resolve(context.Background(), Identity{Name: "orders", Branch: "master", Environment: "staging", Version: 2})
+	if err != nil {
+		t.Fatalf("TestSRVResolver_Resolve_service: %s", err)
+	}
+
+	if len(urls) != 1 || urls[0].Host != "orders-0.local:8081" {
+		t.Errorf("TestSRVResolver_Resolve_service: expected orders-0.local:8081 got %v", urls)
+	}
+}
+
+func TestSRVResolver_Resolve_searchDomain(t *testing.T) {
+	// The relative name only resolves through the cluster search domain.
+	server, err := dnssrvtest.NewServer(map[string][]dnssrv.Record{
+		"orders-master-staging.orders.svc.cluster.local": {
+			{Target: "orders-0.local", Port: 8081, TTL: 30 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("TestSRVResolver_Resolve_searchDomain: %s", err)
+	}
+	defer server.Close()
+
+	resolver := NewSRVResolver(&dnssrv.Client{
+		Server: server.Addr,
+		Search: []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		NDots:  5,
+	})
+
+	urls, err := resolver.Resolve(context.Background(), Identity{Name: "orders", Branch: "master", Environment: "staging"})
+	if err != nil {
+		t.Fatalf("TestSRVResolver_Resolve_searchDomain: %s", err)
+	}
+
+	if len(urls) != 1 || urls[0].Host != "orders-0.local:8081" {
+		t.Errorf("TestSRVResolver_Resolve_searchDomain: expected orders-0.local:8081 got %v", urls)
+	}
+}
+
+func TestSRVResolver_Resolve_literal(t *testing.T) {
+	server, err := dnssrvtest.NewServer(map[string][]dnssrv.Record{
+		"orders-master-staging.orders": {
+			{Target: "orders-0.local", Port: 8081, TTL: 30 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("TestSRVResolver_Resolve_literal: %s", err)
+	}
+	defer server.Close()
+
+	identity := Identity{Name: "orders", Branch: "master", Environment: "staging"}
+
+	resolver := &SRVResolver{Client: &dnssrv.Client{Server: server.Addr}}
+	for i := 0; i < 2; i++ {
+		urls, err := resolver.Resolve(context.Background(), identity)
+		if err != nil {
+			t.Fatalf("TestSRVResolver_Resolve_literal: %s", err)
+		}
+		if len(urls) != 1 || urls[0].Host != "orders-0.local:8081" {
+			t.Errorf("TestSRVResolver_Resolve_literal: expected orders-0.local:8081 got %v", urls)
+		}
+	}
+	if server.Queries() != 1 {
+		t.Errorf("TestSRVResolver_Resolve_literal: expected 1 query got %d", server.Queries())
+	}
+
+	if _, err := (&SRVResolver{}).Resolve(context.Background(), identity); err == nil {
+		t.Errorf("TestSRVResolver_Resolve_literal: expected an error without a client")
+	}
+}
+
+func TestOrderSRV(t *testing.T) {
+	records := []dnssrv.Record{
+		{Target: "backup", Priority: 2, Weight: 100},
+		{Target: "heavy", Priority: 1, Weight: 90},
+		{Target: "light", Priority: 1, Weight: 10},
+		{Target: "unweighted", Priority: 1},
+	}
+
+	firsts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		ordered := orderSRV(records)
+
+		if ordered[3].Target != "backup" {
+			t.Fatalf("TestOrderSRV: expected backup last got %v", ordered)
+		}
+		if ordered[2].Target != "unweighted" {
+			t.Fatalf("TestOrderSRV: expected unweighted after weighted records got %v", ordered)
+		}
+
+		firsts[ordered[0].Target]++
+	}
+
+	// Heavy should be picked first about 900 times.
+	if firsts["heavy"] < 800 || firsts["light"] == 0 {
+		t.Errorf("TestOrderSRV: expected calls spread by weight got %v", firsts)
+	}
+}
+
+func TestService_Dial_srvResolver(t *testing.T) {
+	server, err := dnssrvtest.NewServer(map[string][]dnssrv.Record{
+		"orders-master-staging.orders": {
+			{Target: "orders-0.local.", Port: 8081, TTL: 30 * time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("TestService_Dial_srvResolver: %s", err)
+	}
+	defer server.Close()
+
+	service := NewService(DefaultHttpClient(), "master", "staging", "services", "orders")
+	service.Resolver = NewSRVResolver(&dnssrv.Client{Server: server.Addr})
+
+	err = service.Dial(&Request{
+		Method:   http.MethodGet,
+		Resource: "things",
+	})
+	if err != nil {
+		t.Fatalf("TestService_Dial_srvResolver: %s", err)
+	}
+
+	expectedUrl := "http://orders-0.local:8081/things"
+	if service.CurrentRequest.URL.String() != expectedUrl {
+		t.Errorf("TestService_Dial_srvResolver: expected %v got %v", expectedUrl, service.CurrentRequest.URL.String())
+	}
+}
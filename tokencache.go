@@ -0,0 +1,171 @@
+package microservicetransport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/models"
+)
+
+const (
+	// DefaultTokenRefreshWindow - How long before it expires a cached token
+	// is refreshed.
+	DefaultTokenRefreshWindow = 30 * time.Second
+
+	// DefaultTokenTTL - How long a token is cached for when its expiry cannot
+	// be determined.
+	DefaultTokenTTL = 5 * time.Minute
+)
+
+// DefaultTokenCache - Token cache shared by every cloud service in the process
+// that does not have its own.
+var DefaultTokenCache = NewTokenCache()
+
+// TokenCache - Caches API gateway tokens keyed by gateway URL and credentials.
+// A TokenCache is safe for concurrent use. The zero value is ready to use,
+// but refreshes tokens only once they expire and does not cache tokens with
+// no known expiry; use NewTokenCache for the defaults.
+type TokenCache struct {
+	RefreshWindow time.Duration // How long before expiry a token is refreshed.
+	DefaultTTL    time.Duration // How long to cache tokens with no known expiry.
+
+	mu      sync.Mutex
+	entries map[string]*tokenCacheEntry
+	now     func() time.Time
+}
+
+// tokenCacheEntry - A cached token, along with the login fetching a new one
+// if there is one in flight, so concurrent callers share a single login.
+type tokenCacheEntry struct {
+	token     *models.Token
+	expiresAt time.Time
+	login     *tokenLogin
+}
+
+// tokenLogin - A login in flight, with its outcome once done is closed.
+type tokenLogin struct {
+	done  chan struct{}
+	token *models.Token
+	err   error
+}
+
+// NewTokenCache - Prepare a new, empty token cache.
+func NewTokenCache() *TokenCache {
+	return &TokenCache{
+		RefreshWindow: DefaultTokenRefreshWindow,
+		DefaultTTL:    DefaultTokenTTL,
+		entries:       make(map[string]*tokenCacheEntry),
+		now:           time.Now,
+	}
+}
+
+// Get - Get the cached token for a key, calling login to fetch a new one if
+// there is no token cached or it is about to expire.
+//
+// Callers arriving while a login for the key is in flight wait for its
+// outcome instead of logging in again, or until their context is done. If
+// the login was aborted by the context of the caller making it, the next
+// caller still waiting logs in itself.
+func (tc *TokenCache) Get(ctx context.Context, key string, login func() (*models.Token, error)) (*models.Token, error) {
+	for {
+		tc.mu.Lock()
+		entry := tc.entry(key)
+
+		if entry.token != nil && tc.currentTime().Before(entry.expiresAt.Add(-tc.RefreshWindow)) {
+			tc.mu.Unlock()
+			return entry.token, nil
+		}
+
+		if inFlight := entry.login; inFlight != nil {
+			tc.mu.Unlock()
+
+			select {
+			case <-inFlight.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			if errors.Is(inFlight.err, context.Canceled) || errors.Is(inFlight.err, context.DeadlineExceeded) {
+				continue
+			}
+
+			return inFlight.token, inFlight.err
+		}
+
+		inFlight := &tokenLogin{done: make(chan struct{})}
+		entry.login = inFlight
+		tc.mu.Unlock()
+
+		inFlight.token, inFlight.err = login()
+
+		tc.mu.Lock()
+		entry.login = nil
+		if inFlight.err == nil {
+			entry.token = inFlight.token
+			entry.expiresAt = tc.expiryOf(inFlight.token)
+		}
+		tc.mu.Unlock()
+		close(inFlight.done)
+
+		return inFlight.token, inFlight.err
+	}
+}
+
+// Invalidate - Remove a token from the cache, so the next call to Get logs
+// in again. Nothing happens if the token has already been replaced.
+func (tc *TokenCache) Invalidate(key string, token *models.Token) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if entry := tc.entry(key); entry.token == token {
+		entry.token = nil
+	}
+}
+
+// entry - Get the cache entry for a key, creating it if needed. The cache
+// lock must be held.
+func (tc *TokenCache) entry(key string) *tokenCacheEntry {
+	if tc.entries == nil {
+		tc.entries = make(map[string]*tokenCacheEntry)
+	}
+
+	entry, ok := tc.entries[key]
+	if !ok {
+		entry = &tokenCacheEntry{}
+		tc.entries[key] = entry
+	}
+
+	return entry
+}
+
+// currentTime - Get the time from the cache clock, or the wall clock if it
+// has none.
+func (tc *TokenCache) currentTime() time.Time {
+	if tc.now == nil {
+		return time.Now()
+	}
+
+	return tc.now()
+}
+
+// expiryOf - Work out when a token should be treated as expired.
+func (tc *TokenCache) expiryOf(token *models.Token) time.Time {
+	expiry, err := token.GetExpiry()
+	if err != nil {
+		return tc.currentTime().Add(tc.DefaultTTL)
+	}
+
+	return expiry
+}
+
+// tokenCacheKey - Build the cache key for a gateway URL and set of credentials.
+func tokenCacheKey(gatewayUrl string, credentials *AuthCredentials) string {
+	password := sha256.Sum256([]byte(credentials.Password))
+
+	return strings.Join([]string{gatewayUrl, credentials.Email, hex.EncodeToString(password[:])}, "|")
+}
@@ -0,0 +1,234 @@
+package microservicetransport
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LUSHDigital/microservice-transport-golang/models"
+)
+
+// jwtExpiringAt - Build an unsigned JWT token expiring at the provided time.
+func jwtExpiringAt(expiry time.Time) *models.Token {
+	claims := fmt.Sprintf(`{"exp":%d}`, expiry.Unix())
+
+	return &models.Token{
+		Type:  models.TokenTypeJWT,
+		Value: "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl",
+	}
+}
+
+func TestTokenCache_Get(t *testing.T) {
+	now := time.Date(2017, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name          string
+		cached        *models.Token
+		elapsed       time.Duration
+		expectedLogin bool
+	}{
+		{
+			name:          "Nothing cached",
+			expectedLogin: true,
+		},
+		{
+			name:          "Valid JWT",
+			cached:        jwtExpiringAt(now.Add(time.Hour)),
+			elapsed:       time.Minute,
+			expectedLogin: false,
+		},
+		{
+			name:          "JWT inside refresh window",
+			cached:        jwtExpiringAt(now.Add(time.Hour)),
+			elapsed:       time.Hour - 10*time.Second,
+			expectedLogin: true,
+		},
+		{
+			name:          "Expired JWT",
+			cached:        jwtExpiringAt(now.Add(time.Hour)),
+			elapsed:       2 * time.Hour,
+			expectedLogin: true,
+		},
+		{
+			name:          "Random token inside default TTL",
+			cached:        &models.Token{Type: "random", Value: "xxxx"},
+			elapsed:       time.Minute,
+			expectedLogin: false,
+		},
+		{
+			name:          "Random token after default TTL",
+			cached:        &models.Token{Type: "random", Value: "xxxx"},
+			elapsed:       DefaultTokenTTL,
+			expectedLogin: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := now
+			cache := NewTokenCache()
+			cache.now = func() time.Time { return clock }
+
+			if tc.cached != nil {
+				cache.Get(context.Background(), "key", func() (*models.Token, error) { return tc.cached, nil })
+			}
+
+			clock = clock.Add(tc.elapsed)
+
+			fresh := &models.Token{Type: "random", Value: "fresh"}
+			var loggedIn bool
+			token, err := cache.Get(context.Background(), "key", func() (*models.Token, error) {
+				loggedIn = true
+				return fresh, nil
+			})
+			if err != nil {
+				t.Fatalf("TestTokenCache_Get: %s: %s", tc.name, err)
+			}
+
+			if loggedIn != tc.expectedLogin {
+				t.Errorf("TestTokenCache_Get: %s: expected login %v got %v", tc.name, tc.expectedLogin, loggedIn)
+			}
+
+			if loggedIn && token != fresh {
+				t.Errorf("TestTokenCache_Get: %s: expected %v got %v", tc.name, fresh, token)
+			}
+		})
+	}
+}
+
+func TestTokenCache_Get_concurrent(t *testing.T) {
+	cache := NewTokenCache()
+
+	var (
+		mu     sync.Mutex
+		logins int
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Get(context.Background(), "key", func() (*models.Token, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				logins++
+				time.Sleep(10 * time.Millisecond)
+				return &models.Token{Type: "random", Value: "xxxx"}, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if logins != 1 {
+		t.Errorf("TestTokenCache_Get_concurrent: expected %v got %v", 1, logins)
+	}
+}
+
+func TestTokenCache_Invalidate(t *testing.T) {
+	cache := NewTokenCache()
+
+	stale := &models.Token{Type: "random", Value: "stale"}
+	cache.Get(context.Background(), "key", func() (*models.Token, error) { return stale, nil })
+	cache.Invalidate("key", stale)
+
+	fresh := &models.Token{Type: "random", Value: "fresh"}
+	token, _ := cache.Get(context.Background(), "key", func() (*models.Token, error) { return fresh, nil })
+	if token != fresh {
+		t.Errorf("TestTokenCache_Invalidate: expected %v got %v", fresh, token)
+	}
+
+	// Invalidating a token which has already been replaced does nothing.
+	cache.Invalidate("key", stale)
+	token, _ = cache.Get(context.Background(), "key", func() (*models.Token, error) { return stale, nil })
+	if token != fresh {
+		t.Errorf("TestTokenCache_Invalidate: expected %v got %v", fresh, token)
+	}
+}
+
+func TestTokenCache_Get_zeroValue(t *testing.T) {
+	cache := &TokenCache{}
+
+	var logins int
+	login := func() (*models.Token, error) {
+		logins++
+		return jwtExpiringAt(time.Now().Add(time.Hour)), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get(context.Background(), "key", login); err != nil {
+			t.Fatalf("TestTokenCache_Get_zeroValue: %s", err)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("TestTokenCache_Get_zeroValue: expected 1 login got %d", logins)
+	}
+
+	// Invalidating an unknown key does not panic either.
+	(&TokenCache{}).Invalidate("key", nil)
+}
+
+func TestTokenCache_Get_waitContext(t *testing.T) {
+	cache := NewTokenCache()
+
+	// Hold a login in flight until the test is over.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go cache.Get(context.Background(), "key", func() (*models.Token, error) {
+		close(started)
+		<-release
+		return &models.Token{Type: "random", Value: "slow"}, nil
+	})
+	<-started
+	defer close(release)
+
+	// A caller waiting on it gives up when its own context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := cache.Get(ctx, "key", func() (*models.Token, error) {
+		t.Errorf("TestTokenCache_Get_waitContext: expected no second login")
+		return nil, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TestTokenCache_Get_waitContext: expected %v got %v", context.DeadlineExceeded, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("TestTokenCache_Get_waitContext: expected the wait to be aborted, took %v", elapsed)
+	}
+}
+
+func TestTokenCache_Get_abortedLogin(t *testing.T) {
+	cache := NewTokenCache()
+
+	// Start a login which is aborted by the context of its caller once a
+	// second caller is waiting on it.
+	abort := make(chan struct{})
+	started := make(chan struct{})
+	go cache.Get(context.Background(), "key", func() (*models.Token, error) {
+		close(started)
+		<-abort
+		return nil, fmt.Errorf("cannot login: %w", context.Canceled)
+	})
+	<-started
+
+	time.AfterFunc(50*time.Millisecond, func() { close(abort) })
+
+	// The waiting caller logs in itself rather than failing with the
+	// context error of the other caller.
+	fresh := &models.Token{Type: "random", Value: "fresh"}
+	token, err := cache.Get(context.Background(), "key", func() (*models.Token, error) {
+		return fresh, nil
+	})
+	if err != nil {
+		t.Fatalf("TestTokenCache_Get_abortedLogin: %s", err)
+	}
+	if token != fresh {
+		t.Errorf("TestTokenCache_Get_abortedLogin: expected %v got %v", fresh, token)
+	}
+}
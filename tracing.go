@@ -0,0 +1,226 @@
+package microservicetransport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TraceparentHeader - Name of the W3C header carrying the trace context.
+	TraceparentHeader = "traceparent"
+
+	// TracestateHeader - Name of the W3C header carrying vendor trace state.
+	TracestateHeader = "tracestate"
+)
+
+// SpanContext - Identifies a span within a trace, as carried by the W3C
+// traceparent and tracestate headers.
+type SpanContext struct {
+	TraceID    [16]byte // ID of the trace the span belongs to.
+	SpanID     [8]byte  // ID of the span.
+	Sampled    bool     // Whether the trace is recorded.
+	TraceState string   // Vendor trace state, passed on untouched.
+}
+
+// spanContextKey - Key of the span context in a context.
+type spanContextKey struct{}
+
+// ContextWithSpanContext - Get a context carrying the span context, so calls
+// made with it are traced as its children.
+func ContextWithSpanContext(ctx context.Context, spanContext SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, spanContext)
+}
+
+// SpanContextFromContext - Get the span context carried by a context, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	spanContext, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return spanContext, ok
+}
+
+// ExtractTraceContext - Get a context carrying the span context from the
+// traceparent and tracestate headers of an inbound request. The context is
+// returned as it is if the headers do not hold a valid trace context.
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	spanContext, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	spanContext.TraceState = header.Get(TracestateHeader)
+
+	return ContextWithSpanContext(ctx, spanContext)
+}
+
+// ParseTraceparent - Parse a W3C traceparent header value, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	spanContext := SpanContext{}
+
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return spanContext, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+
+	if _, err := hex.Decode(spanContext.TraceID[:], []byte(parts[1])); err != nil || len(parts[1]) != 32 {
+		return spanContext, fmt.Errorf("invalid traceparent %q: malformed trace id", traceparent)
+	}
+	if _, err := hex.Decode(spanContext.SpanID[:], []byte(parts[2])); err != nil || len(parts[2]) != 16 {
+		return spanContext, fmt.Errorf("invalid traceparent %q: malformed span id", traceparent)
+	}
+	if spanContext.TraceID == [16]byte{} || spanContext.SpanID == [8]byte{} {
+		return spanContext, fmt.Errorf("invalid traceparent %q: zero id", traceparent)
+	}
+
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || len(parts[3]) != 2 {
+		return spanContext, fmt.Errorf("invalid traceparent %q: malformed flags", traceparent)
+	}
+	spanContext.Sampled = flags&1 == 1
+
+	return spanContext, nil
+}
+
+// Traceparent - Get the span context as a W3C traceparent header value.
+func (s SpanContext) Traceparent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(s.TraceID[:]), hex.EncodeToString(s.SpanID[:]), flags)
+}
+
+// Span - A client span recorded for a request sent to a service.
+type Span struct {
+	Name         string            // Name of the span, from the service namespace, name and resource template.
+	Context      SpanContext       // Span context sent with the request.
+	ParentSpanID [8]byte           // ID of the span of the caller, zero for root spans.
+	Start        time.Time         // When the request was sent.
+	End          time.Time         // When the response or error came back.
+	Attributes   map[string]string // Details of the request and response.
+	Error        string            // Why the request failed, if it did.
+}
+
+// SpanExporter - Receives every span recorded by a tracer, e.g. to send it
+// to a tracing backend. Exporters must be safe for concurrent use.
+type SpanExporter interface {
+	// ExportSpan - Export a finished span.
+	ExportSpan(span Span)
+}
+
+// InMemoryExporter - Holds exported spans in memory, for use in tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+// ExportSpan - Hold on to a finished span.
+func (e *InMemoryExporter) ExportSpan(span Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, span)
+}
+
+// Spans - Get the spans exported so far, in order.
+func (e *InMemoryExporter) Spans() []Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Span(nil), e.spans...)
+}
+
+// Reset - Forget the spans exported so far.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
+
+// Tracer - Creates a client span for each request sent to a service, and
+// propagates its trace context in the traceparent and tracestate headers.
+// Requests made with a context carrying a span context are traced as its
+// children, otherwise a new trace is started.
+//
+//	service.Tracer = NewTracer(exporter)
+type Tracer struct {
+	Exporter SpanExporter // Exporter sampled spans are sent to, nil to only propagate trace context.
+}
+
+// NewTracer - Prepare a new tracer exporting spans to the provided exporter.
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{Exporter: exporter}
+}
+
+// traceCall - Do a request, tracing it as a call to a resource of the
+// service if there is a tracer.
+func (t *Tracer) traceCall(identity Identity, attempt int, resource string, req *http.Request, do func() (*http.Response, error)) (*http.Response, error) {
+	if t == nil {
+		return do()
+	}
+
+	span := Span{
+		Name:    fmt.Sprintf("%s/%s/%s", identity.Namespace, identity.Name, resource),
+		Context: SpanContext{Sampled: true},
+		Start:   time.Now(),
+		Attributes: map[string]string{
+			"service.name":      identity.Name,
+			"service.namespace": identity.Namespace,
+			"http.method":       req.Method,
+			"http.url":          req.URL.String(),
+			"attempt":           strconv.Itoa(attempt),
+		},
+	}
+
+	if parent, ok := SpanContextFromContext(req.Context()); ok {
+		span.Context = parent
+		span.ParentSpanID = parent.SpanID
+	} else if err := randomID(span.Context.TraceID[:]); err != nil {
+		return nil, fmt.Errorf("cannot start trace: %w", err)
+	}
+	if err := randomID(span.Context.SpanID[:]); err != nil {
+		return nil, fmt.Errorf("cannot start span: %w", err)
+	}
+
+	req.Header.Set(TraceparentHeader, span.Context.Traceparent())
+	if span.Context.TraceState != "" {
+		req.Header.Set(TracestateHeader, span.Context.TraceState)
+	}
+
+	resp, err := do()
+
+	span.End = time.Now()
+	if err != nil {
+		span.Error = err.Error()
+	} else {
+		span.Attributes["http.status_code"] = strconv.Itoa(resp.StatusCode)
+	}
+
+	if t.Exporter != nil && span.Context.Sampled {
+		t.Exporter.ExportSpan(span)
+	}
+
+	return resp, err
+}
+
+// randomID - Fill an ID with random bytes, making sure it is not all zeros.
+func randomID(id []byte) error {
+	for {
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+
+		for _, b := range id {
+			if b != 0 {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package microservicetransport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tt := []struct {
+		name            string
+		traceparent     string
+		expectedSampled bool
+		expectedErr     bool
+	}{
+		{
+			name:            "Sampled",
+			traceparent:     "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			expectedSampled: true,
+		},
+		{
+			name:        "Not sampled",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		},
+		{
+			name:            "Future version",
+			traceparent:     "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			expectedSampled: true,
+		},
+		{
+			name:        "Invalid version",
+			traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			expectedErr: true,
+		},
+		{
+			name:        "Extra fields",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			expectedErr: true,
+		},
+		{
+			name:        "Zero trace id",
+			traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			expectedErr: true,
+		},
+		{
+			name:        "Short span id",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01",
+			expectedErr: true,
+		},
+		{
+			name:        "Malformed flags",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-x1",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			spanContext, err := ParseTraceparent(tc.traceparent)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("TestParseTraceparent: %s: expected error %v got %v", tc.name, tc.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if spanContext.Sampled != tc.expectedSampled {
+				t.Errorf("TestParseTraceparent: %s: expected sampled %v got %v", tc.name, tc.expectedSampled, spanContext.Sampled)
+			}
+		})
+	}
+}
+
+func TestService_Call_tracer(t *testing.T) {
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	parent.TraceState = "vendor=value"
+
+	unsampled, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	tt := []struct {
+		name             string
+		ctx              context.Context
+		expectedParent   *SpanContext
+		expectedExported bool
+	}{
+		{
+			name:             "New trace",
+			ctx:              context.Background(),
+			expectedExported: true,
+		},
+		{
+			name:             "Child of the caller",
+			ctx:              ContextWithSpanContext(context.Background(), parent),
+			expectedParent:   &parent,
+			expectedExported: true,
+		},
+		{
+			name:           "Caller not sampled",
+			ctx:            ContextWithSpanContext(context.Background(), unsampled),
+			expectedParent: &unsampled,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Start a HTTP server to act as the service, capturing the trace
+			// context it receives.
+			var received http.Header
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header
+			}))
+			defer ts.Close()
+
+			exporter := &InMemoryExporter{}
+			service := NewService(redirectClient(ts), "master", "staging", "services", "myservice")
+			service.Tracer = NewTracer(exporter)
+
+			call, err := service.Prepare(tc.ctx, &Request{Method: http.MethodGet, Resource: "things/123"})
+			if err != nil {
+				t.Fatalf("TestService_Call_tracer: %s: %s", tc.name, err)
+			}
+
+			resp, err := call.Do(tc.ctx)
+			if err != nil {
+				t.Fatalf("TestService_Call_tracer: %s: %s", tc.name, err)
+			}
+			resp.Body.Close()
+
+			sent, err := ParseTraceparent(received.Get(TraceparentHeader))
+			if err != nil {
+				t.Fatalf("TestService_Call_tracer: %s: %s", tc.name, err)
+			}
+
+			if tc.expectedParent != nil {
+				if sent.TraceID != tc.expectedParent.TraceID {
+					t.Errorf("TestService_Call_tracer: %s: expected trace %x got %x", tc.name, tc.expectedParent.TraceID, sent.TraceID)
+				}
+				if sent.SpanID == tc.expectedParent.SpanID {
+					t.Errorf("TestService_Call_tracer: %s: expected a new span id got %x", tc.name, sent.SpanID)
+				}
+				if sent.Sampled != tc.expectedParent.Sampled {
+					t.Errorf("TestService_Call_tracer: %s: expected sampled %v got %v", tc.name, tc.expectedParent.Sampled, sent.Sampled)
+				}
+				if received.Get(TracestateHeader) != tc.expectedParent.TraceState {
+					t.Errorf("TestService_Call_tracer: %s: expected tracestate %v got %v", tc.name, tc.expectedParent.TraceState, received.Get(TracestateHeader))
+				}
+			}
+
+			spans := exporter.Spans()
+			if !tc.expectedExported {
+				if len(spans) != 0 {
+					t.Errorf("TestService_Call_tracer: %s: expected no spans got %v", tc.name, spans)
+				}
+				return
+			}
+
+			if len(spans) != 1 {
+				t.Fatalf("TestService_Call_tracer: %s: expected 1 span got %v", tc.name, spans)
+			}
+			span := spans[0]
+
+			// The span is named after the resource template, so names are
+			// not unique to each record.
+			if span.Name != "services/myservice/things/{id}" {
+				t.Errorf("TestService_Call_tracer: %s: expected name %v got %v", tc.name, "services/myservice/things/{id}", span.Name)
+			}
+			if span.Context.TraceID != sent.TraceID || span.Context.SpanID != sent.SpanID {
+				t.Errorf("TestService_Call_tracer: %s: expected the span sent got %v", tc.name, span.Context)
+			}
+			if tc.expectedParent != nil && span.ParentSpanID != tc.expectedParent.SpanID {
+				t.Errorf("TestService_Call_tracer: %s: expected parent %x got %x", tc.name, tc.expectedParent.SpanID, span.ParentSpanID)
+			}
+			if span.Attributes["http.status_code"] != "200" {
+				t.Errorf("TestService_Call_tracer: %s: expected status 200 got %v", tc.name, span.Attributes["http.status_code"])
+			}
+		})
+	}
+}
+
+func TestExtractTraceContext(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(TracestateHeader, "vendor=value")
+
+	spanContext, ok := SpanContextFromContext(ExtractTraceContext(context.Background(), header))
+	if !ok {
+		t.Fatalf("TestExtractTraceContext: expected a span context")
+	}
+
+	if spanContext.Traceparent() != header.Get(TraceparentHeader) || spanContext.TraceState != "vendor=value" {
+		t.Errorf("TestExtractTraceContext: expected %v got %v", header, spanContext)
+	}
+
+	if _, ok := SpanContextFromContext(ExtractTraceContext(context.Background(), http.Header{})); ok {
+		t.Errorf("TestExtractTraceContext: expected no span context without headers")
+	}
+}
@@ -1,15 +1,40 @@
 package microservicetransport
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // Transport - Interface responsible for communication.
+//
+// A transport keeps the request it has dialled until it is called, so it must
+// not be shared between goroutines. Use a Dialer for concurrent calls.
 type Transport interface {
 	// Call - Do the current service request.
 	Call() (*http.Response, error)
 
+	// CallContext - Do the current service request, bound to the provided
+	// context.
+	CallContext(ctx context.Context) (*http.Response, error)
+
 	// Dial - Create a request to a service resource.
 	Dial(request *Request) error
 
+	// DialContext - Create a request to a service resource, bound to the
+	// provided context.
+	DialContext(ctx context.Context, request *Request) error
+
 	// Dial - Get the name of the service
 	GetName() string
 }
+
+// Dialer - Interface for transports which prepare calls without holding any
+// state of their own, making them safe for concurrent use.
+type Dialer interface {
+	// Prepare - Prepare a call to a service resource, bound to the provided
+	// context.
+	Prepare(ctx context.Context, request *Request) (*PreparedCall, error)
+
+	// GetName - Get the name of the service
+	GetName() string
+}